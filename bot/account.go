@@ -0,0 +1,278 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+)
+
+const accountRefreshEvery = time.Minute
+
+// Balance is one coin in the account. Free is what can be spent right now,
+// Locked sits in open orders and Equity includes unrealised PNL.
+type Balance struct {
+	Coin   string
+	Free   float64
+	Locked float64
+	Equity float64
+}
+
+// AccountService reads balances from an exchange and keeps them cached
+type AccountService interface {
+	Refresh() error
+	Balance(coin string) Balance
+	Balances() map[string]Balance
+	Updated() time.Time
+}
+
+// balanceCache is the cached part shared by every exchange's account
+type balanceCache struct {
+	mu       sync.RWMutex
+	balances map[string]Balance
+	updated  time.Time
+}
+
+func (c *balanceCache) set(balances map[string]Balance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances = balances
+	c.updated = time.Now()
+}
+
+func (c *balanceCache) Balance(coin string) Balance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if b, ok := c.balances[coin]; ok {
+		return b
+	}
+	return Balance{Coin: coin}
+}
+
+func (c *balanceCache) Balances() map[string]Balance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]Balance, len(c.balances))
+	for k, v := range c.balances {
+		out[k] = v
+	}
+	return out
+}
+
+func (c *balanceCache) Updated() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.updated
+}
+
+// RunAccountRefresh refreshes the balances on a schedule until the process exits
+func RunAccountRefresh(a AccountService) {
+	ticker := time.NewTicker(accountRefreshEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := a.Refresh(); err != nil {
+			log.Printf("Account refresh error: %v", err)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Bybit
+////////////////////////////////////////////////////////////
+
+type BybitAccount struct {
+	balanceCache
+	client      *bybit.Client
+	accountType string
+}
+
+// NewBybitAccount reads a Bybit wallet; accountType is UNIFIED, SPOT or CONTRACT
+func NewBybitAccount(client *bybit.Client, accountType string) *BybitAccount {
+	return &BybitAccount{client: client, accountType: accountType}
+}
+
+func (a *BybitAccount) Refresh() error {
+	params := map[string]interface{}{"accountType": a.accountType}
+
+	var data struct {
+		List []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+				Equity        string `json:"equity"`
+				Locked        string `json:"locked"`
+			} `json:"coin"`
+		} `json:"list"`
+	}
+	err := withRetry("Bybit wallet", func() error {
+		res, err := a.client.NewUtaBybitServiceWithParams(params).GetAccountWallet(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return err
+	}
+	if len(data.List) == 0 {
+		return fmt.Errorf("bybit %s account not found", a.accountType)
+	}
+
+	balances := map[string]Balance{}
+	for _, c := range data.List[0].Coin {
+		wallet := parseStringToFloat(c.WalletBalance)
+		locked := parseStringToFloat(c.Locked)
+		balances[c.Coin] = Balance{
+			Coin:   c.Coin,
+			Free:   max(wallet-locked, 0),
+			Locked: locked,
+			Equity: parseStringToFloat(c.Equity),
+		}
+	}
+	a.set(balances)
+	return nil
+}
+
+////////////////////////////////////////////////////////////
+// Binance Futures
+////////////////////////////////////////////////////////////
+
+type BinanceFuturesAccount struct {
+	balanceCache
+	env Environment
+}
+
+func NewBinanceFuturesAccount(env Environment) *BinanceFuturesAccount {
+	return &BinanceFuturesAccount{env: env}
+}
+
+func (a *BinanceFuturesAccount) Refresh() error {
+	var data []struct {
+		Asset            string `json:"asset"`
+		Balance          string `json:"balance"`
+		AvailableBalance string `json:"availableBalance"`
+		CrossUnPnl       string `json:"crossUnPnl"`
+	}
+	err := withRetry("Binance balance", func() error {
+		body, err := binanceSigned(a.env, http.MethodGet, a.env.BinanceFuturesREST+"/fapi/v2/balance", url.Values{}, PriorityMarketData, 5)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &data)
+	})
+	if err != nil {
+		return err
+	}
+
+	balances := map[string]Balance{}
+	for _, c := range data {
+		wallet := parseStringToFloat(c.Balance)
+		free := parseStringToFloat(c.AvailableBalance)
+		balances[c.Asset] = Balance{
+			Coin:   c.Asset,
+			Free:   free,
+			Locked: max(wallet-free, 0),
+			Equity: wallet + parseStringToFloat(c.CrossUnPnl),
+		}
+	}
+	a.set(balances)
+	return nil
+}
+
+////////////////////////////////////////////////////////////
+// Binance Spot
+////////////////////////////////////////////////////////////
+
+type BinanceSpotAccount struct {
+	balanceCache
+	env Environment
+}
+
+func NewBinanceSpotAccount(env Environment) *BinanceSpotAccount {
+	return &BinanceSpotAccount{env: env}
+}
+
+func (a *BinanceSpotAccount) Refresh() error {
+	params := url.Values{}
+	params.Set("omitZeroBalances", "true")
+
+	var data struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	err := withRetry("Binance spot account", func() error {
+		body, err := binanceSigned(a.env, http.MethodGet, a.env.BinanceSpotREST+"/api/v3/account", params, PriorityMarketData, 20)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &data)
+	})
+	if err != nil {
+		return err
+	}
+
+	balances := map[string]Balance{}
+	for _, c := range data.Balances {
+		free := parseStringToFloat(c.Free)
+		locked := parseStringToFloat(c.Locked)
+		balances[c.Asset] = Balance{
+			Coin:   c.Asset,
+			Free:   free,
+			Locked: locked,
+			Equity: free + locked,
+		}
+	}
+	a.set(balances)
+	return nil
+}
+
+////////////////////////////////////////////////////////////
+// OKX
+////////////////////////////////////////////////////////////
+
+type OKXAccount struct {
+	balanceCache
+	okx *OKX
+}
+
+func NewOKXAccount(okx *OKX) *OKXAccount {
+	return &OKXAccount{okx: okx}
+}
+
+func (a *OKXAccount) Refresh() error {
+	var data []struct {
+		Details []struct {
+			Ccy       string `json:"ccy"`
+			AvailBal  string `json:"availBal"`
+			FrozenBal string `json:"frozenBal"`
+			Eq        string `json:"eq"`
+		} `json:"details"`
+	}
+	err := withRetry("OKX balance", func() error {
+		return a.okx.do(http.MethodGet, "/api/v5/account/balance", nil, nil, true, PriorityMarketData, &data)
+	})
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("okx account not found")
+	}
+
+	balances := map[string]Balance{}
+	for _, c := range data[0].Details {
+		balances[c.Ccy] = Balance{
+			Coin:   c.Ccy,
+			Free:   parseStringToFloat(c.AvailBal),
+			Locked: parseStringToFloat(c.FrozenBal),
+			Equity: parseStringToFloat(c.Eq),
+		}
+	}
+	a.set(balances)
+	return nil
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// ATR-Adaptive Steps
+////////////////////////////////////////////////////////////
+
+// ATRStep derives the buy spacing and the sell target from the average true
+// range instead of fixed percentages: buy after a move of DropMultiplier x
+// ATR, take profit SellMultiplier x ATR past the average. A multiplier of 0
+// keeps the fixed percentage for that side.
+type ATRStep struct {
+	Interval       string
+	Period         int
+	DropMultiplier float64
+	SellMultiplier float64
+}
+
+func (s ATRStep) Enabled() bool {
+	return s.Interval != "" && (s.DropMultiplier > 0 || s.SellMultiplier > 0)
+}
+
+func (s ATRStep) String() string {
+	if !s.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("ATR(%d) %s, drop x%g, sell x%g", s.Period, s.Interval, s.DropMultiplier, s.SellMultiplier)
+}
+
+// dropStep is the move that triggers the next buy, in percent, widened or
+// narrowed by the regime
+func (b *DCABot) dropStep() float64 {
+	step := b.DropPercent
+	if b.ATRStep.DropMultiplier > 0 && b.atrPercent > 0 {
+		step = b.ATRStep.DropMultiplier * b.atrPercent
+	}
+	return step * b.regimeRule().DropScale
+}
+
+// sellStep is the profit target past the average, in percent
+func (b *DCABot) sellStep() float64 {
+	if b.ATRStep.SellMultiplier > 0 && b.atrPercent > 0 {
+		return b.ATRStep.SellMultiplier * b.atrPercent
+	}
+	return b.SellPercent
+}
+
+// refreshATR recalculates the ATR once per candle of the chosen timeframe.
+// Until the first one succeeds the fixed percentages stay in use.
+func (b *DCABot) refreshATR(price float64, token string) {
+	if !b.ATRStep.Enabled() || b.Candles == nil {
+		return
+	}
+	every := intervalDuration(b.ATRStep.Interval)
+	if every == 0 {
+		every = time.Hour
+	}
+	if time.Since(b.lastATRCheck) < every {
+		return
+	}
+	b.lastATRCheck = time.Now()
+
+	// Period true ranges need Period+1 closed candles, one more covers the
+	// forming candle the feeds drop
+	candles, err := b.Candles.Candles(b.Symbol, b.ATRStep.Interval, b.ATRStep.Period+2)
+	if err != nil {
+		log.Printf("%s ATR error: %v", b.Symbol, err)
+		return
+	}
+	ranges := make([]FixRangeCandle, len(candles))
+	for i, c := range candles {
+		ranges[i] = FixRangeCandle{High: c.High, Low: c.Low, Close: c.Close}
+	}
+	atr := calculateATR(ranges, b.ATRStep.Period)
+	if atr == 0 || price == 0 {
+		log.Printf("%s ATR: only %d %s candles, keeping %.2f%% / %.2f%%", b.Symbol, len(candles), b.ATRStep.Interval, b.dropStep(), b.sellStep())
+		return
+	}
+
+	oldSell := b.sellStep()
+	b.ATR = atr
+	b.atrPercent = atr / price * 100
+	fmt.Printf("ATR %s → %.4f (%.2f%%), drop %.2f%%, sell %.2f%%\n", b.ATRStep.Interval, atr, b.atrPercent, b.dropStep(), b.sellStep())
+
+	// A resting take profit has to follow the new target
+	if b.TPOrderID != "" && len(b.TPLadder) == 0 && math.Abs(b.sellStep()-oldSell) >= 0.01 {
+		b.syncTakeProfit(token)
+	}
+}
+
+// atrStatus is the thresholds line for buy alerts, /status and the daily
+// report
+func (b *DCABot) atrStatus() string {
+	if !b.ATRStep.Enabled() {
+		return ""
+	}
+	if b.atrPercent == 0 {
+		return fmt.Sprintf("Thresholds: drop %.2f%%, sell %.2f%% (fixed, waiting for ATR)", b.dropStep(), b.sellStep())
+	}
+	return fmt.Sprintf("Thresholds: drop %.2f%%, sell %.2f%% (ATR %.4f = %.2f%% on %s)",
+		b.dropStep(), b.sellStep(), b.ATR, b.atrPercent, b.ATRStep.Interval)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// closedCandleFeed answers like the exchanges do: the newest of the limit
+// candles is still forming and left out
+type closedCandleFeed struct {
+	candles []Candle
+	limits  []int
+}
+
+func (f *closedCandleFeed) Candles(symbol, interval string, limit int) ([]Candle, error) {
+	f.limits = append(f.limits, limit)
+	n := min(limit, len(f.candles)+1) - 1
+	return f.candles[len(f.candles)-n:], nil
+}
+
+func (f *closedCandleFeed) StreamCandles(symbol, interval string, onCandle func(Candle)) error {
+	return nil
+}
+
+func TestRefreshATRCandleCount(t *testing.T) {
+	feed := &closedCandleFeed{}
+	for i := 0; i < 50; i++ {
+		feed.candles = append(feed.candles, Candle{High: 102, Low: 98, Close: 100, CloseTime: time.Now().Add(time.Duration(i-50) * time.Hour)})
+	}
+	b := &DCABot{
+		Symbol:      "BTCUSDT",
+		DropPercent: 1,
+		SellPercent: 1,
+		Candles:     feed,
+		ATRStep:     ATRStep{Interval: "1h", Period: 14, DropMultiplier: 1},
+	}
+
+	b.refreshATR(100, "")
+	if len(feed.limits) != 1 || feed.limits[0] < b.ATRStep.Period+2 {
+		t.Fatalf("asked for %v candles, a period of %d needs %d with the forming one", feed.limits, b.ATRStep.Period, b.ATRStep.Period+2)
+	}
+	if b.ATR != 4 {
+		t.Fatalf("ATR = %g, want 4", b.ATR)
+	}
+	if got := b.dropStep(); math.Abs(got-4) > 1e-9 {
+		t.Errorf("drop step = %g%%, want 4%%", got)
+	}
+}
@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const binanceOrderNotFound = -2013
+
+// BinanceSpot trades Binance spot with the configured Binance key
+type BinanceSpot struct {
+	env Environment
+}
+
+func NewBinanceSpot(env Environment) *BinanceSpot {
+	binanceClock(env) // synced now rather than on the first order
+	return &BinanceSpot{env: env}
+}
+
+func (e *BinanceSpot) Name() string {
+	return "binance"
+}
+
+type binanceOrder struct {
+	OrderID             int64  `json:"orderId"`
+	ClientOrderID       string `json:"clientOrderId"`
+	Status              string `json:"status"`
+	Price               string `json:"price"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Time                int64  `json:"time"`
+	TransactTime        int64  `json:"transactTime"`
+}
+
+func (o binanceOrder) toOrder() *Order {
+	status := OrderNew
+	switch o.Status {
+	case "PARTIALLY_FILLED":
+		status = OrderPartiallyFilled
+	case "FILLED":
+		status = OrderFilled
+	case "CANCELED", "PENDING_CANCEL", "EXPIRED", "EXPIRED_IN_MATCH":
+		status = OrderCancelled
+	case "REJECTED":
+		status = OrderRejected
+	}
+
+	created := o.Time
+	if created == 0 {
+		created = o.TransactTime
+	}
+
+	order := &Order{
+		ID:          strconv.FormatInt(o.OrderID, 10),
+		LinkID:      o.ClientOrderID,
+		Status:      status,
+		Price:       parseStringToFloat(o.Price),
+		Qty:         parseStringToFloat(o.OrigQty),
+		FilledQty:   parseStringToFloat(o.ExecutedQty),
+		FilledValue: parseStringToFloat(o.CummulativeQuoteQty),
+		Created:     time.UnixMilli(created),
+	}
+	if order.FilledQty > 0 {
+		order.AvgPrice = order.FilledValue / order.FilledQty
+	}
+	return order
+}
+
+func (e *BinanceSpot) Instrument(symbol string) (*Instrument, error) {
+	endpoint := e.env.BinanceSpotREST + "/api/v3/exchangeInfo?symbol=" + url.QueryEscape(symbol)
+
+	var data struct {
+		Symbols []struct {
+			Filters []struct {
+				FilterType  string `json:"filterType"`
+				TickSize    string `json:"tickSize"`
+				StepSize    string `json:"stepSize"`
+				MinQty      string `json:"minQty"`
+				MinNotional string `json:"minNotional"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}
+	err := withRetry("Binance exchange info", func() error {
+		req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := binanceDo(req, PriorityMarketData, 20)
+		if err != nil {
+			return binanceNetworkError(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &ExchangeError{Exchange: "binance", Kind: ErrNetwork, Err: err}
+		}
+		if err := binanceError(resp.StatusCode, body); err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Symbols) == 0 {
+		return nil, fmt.Errorf("instrument %s not found", symbol)
+	}
+
+	instrument := &Instrument{}
+	for _, f := range data.Symbols[0].Filters {
+		switch f.FilterType {
+		case "PRICE_FILTER":
+			instrument.TickSize = parseStringToFloat(f.TickSize)
+		case "LOT_SIZE":
+			instrument.QtyStep = parseStringToFloat(f.StepSize)
+			instrument.MinOrderQty = parseStringToFloat(f.MinQty)
+		case "NOTIONAL", "MIN_NOTIONAL":
+			instrument.MinOrderAmt = parseStringToFloat(f.MinNotional)
+		}
+	}
+	return instrument, nil
+}
+
+// PlaceOrder sends a single attempt. Market buys spend quoteOrderQty, so
+// the exchange works out the base quantity against its own lot size.
+func (e *BinanceSpot) PlaceOrder(req OrderRequest) (*Order, error) {
+	params := url.Values{}
+	params.Set("symbol", req.Symbol)
+	params.Set("side", strings.ToUpper(req.Side))
+	params.Set("type", strings.ToUpper(req.Type))
+	params.Set("newClientOrderId", req.LinkID)
+	params.Set("newOrderRespType", "RESULT")
+	if req.QuoteQty != "" {
+		params.Set("quoteOrderQty", req.QuoteQty)
+	} else {
+		params.Set("quantity", req.Qty)
+	}
+	if req.Type == "Limit" {
+		params.Set("price", req.Price)
+		params.Set("timeInForce", "GTC")
+		if req.TimeInForce != "" {
+			params.Set("timeInForce", req.TimeInForce) // GTC, IOC and FOK read the same
+		}
+	}
+
+	body, err := binanceSigned(e.env, http.MethodPost, e.env.BinanceSpotREST+"/api/v3/order", params, PriorityOrder, 1)
+	if err != nil {
+		return nil, err
+	}
+	var order binanceOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, err
+	}
+	return order.toOrder(), nil
+}
+
+// AmendOrder is not offered on Binance spot; callers cancel and place again
+func (e *BinanceSpot) AmendOrder(symbol, orderID, qty, price string) error {
+	return errAmendUnsupported
+}
+
+func (e *BinanceSpot) CancelOrder(symbol, orderID string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+
+	return withRetry("Binance cancel", func() error {
+		_, err := binanceSigned(e.env, http.MethodDelete, e.env.BinanceSpotREST+"/api/v3/order", params, PriorityOrder, 1)
+		return err
+	})
+}
+
+func (e *BinanceSpot) GetOrder(symbol, orderID string) (*Order, error) {
+	return e.fetchOrder(symbol, "orderId", orderID)
+}
+
+func (e *BinanceSpot) GetOrderByLinkID(symbol, linkID string) (*Order, error) {
+	return e.fetchOrder(symbol, "origClientOrderId", linkID)
+}
+
+func (e *BinanceSpot) fetchOrder(symbol, idKey, id string) (*Order, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set(idKey, id)
+
+	var order binanceOrder
+	err := withRetry("Binance order", func() error {
+		body, err := binanceSigned(e.env, http.MethodGet, e.env.BinanceSpotREST+"/api/v3/order", params, PriorityMarketData, 4)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &order)
+	})
+	if exErr, ok := asExchangeError(err); ok && exErr.Code == binanceOrderNotFound {
+		return nil, errOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order.toOrder(), nil
+}
+
+func (e *BinanceSpot) StreamTrades(symbol string, onPrice func(price float64)) error {
+	wsURL := fmt.Sprintf("%s/ws/%s@aggTrade", e.env.BinanceSpotWS, strings.ToLower(symbol))
+	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	fmt.Printf("✅ Binance WS Connected for %s\n", symbol)
+
+	for {
+		var msg struct {
+			Price string `json:"p"`
+		}
+		if err := c.ReadJSON(&msg); err != nil {
+			return err
+		}
+		if msg.Price != "" {
+			onPrice(parseStringToFloat(msg.Price))
+		}
+	}
+}
+
+func (e *BinanceSpot) Candles(symbol, interval string, limit int) ([]Candle, error) {
+	url := fmt.Sprintf("%s/api/v3/klines?symbol=%s&interval=%s&limit=%d", e.env.BinanceSpotREST, symbol, interval, limit)
+	return binanceKlines(e.env, url, 2)
+}
+
+func (e *BinanceSpot) StreamCandles(symbol, interval string, onCandle func(Candle)) error {
+	urlStr := fmt.Sprintf("%s/ws/%s@kline_%s", e.env.BinanceSpotWS, strings.ToLower(symbol), interval)
+	return streamBinanceKlines(urlStr, onCandle)
+}
@@ -0,0 +1,251 @@
+package bot
+
+import (
+	"bytes"
+	"dca-bot/config"
+	"dca-bot/constant"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// binanceSigned sends a signed request, timed by env's clock, and returns the
+// body of a successful response. Lost GETs come back as network errors so
+// callers can retry them; a lost POST is returned as is because it may have
+// gone through.
+func binanceSigned(env Environment, method, endpoint string, params url.Values, p Priority, weight int) ([]byte, error) {
+	params.Set("recvWindow", strconv.Itoa(recvWindow))
+	params.Set("timestamp", strconv.FormatInt(binanceClock(env).Now().UnixMilli(), 10))
+
+	// The signature has to come last, so it is appended by hand
+	query := params.Encode()
+	query += "&signature=" + sign(query, config.BinanceApiSecret)
+
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, endpoint+"?"+query, nil)
+	} else {
+		req, err = http.NewRequest(method, endpoint, bytes.NewBufferString(query))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-MBX-APIKEY", config.BinanceApiKey)
+
+	resp, err := binanceDo(req, p, weight)
+	if err != nil {
+		if _, ok := asExchangeError(err); ok || method == http.MethodGet {
+			return nil, binanceNetworkError(err)
+		}
+		return nil, fmt.Errorf("binance %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ExchangeError{Exchange: "binance", Kind: ErrNetwork, Err: err}
+	}
+	if err := binanceError(resp.StatusCode, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+////////////////////////////////////////////////////////////
+// Futures Candles & Signal Orders
+////////////////////////////////////////////////////////////
+
+// BinanceFutures serves USDⓈ-M futures klines to the signal bot and places
+// its orders
+type BinanceFutures struct {
+	env Environment
+}
+
+func NewBinanceFutures(env Environment) *BinanceFutures {
+	binanceClock(env) // synced now rather than on the first order
+	return &BinanceFutures{env: env}
+}
+
+func (f *BinanceFutures) Candles(symbol, interval string, limit int) ([]Candle, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines?symbol=%s&interval=%s&limit=%d", f.env.BinanceFuturesREST, symbol, interval, limit)
+	return binanceKlines(f.env, url, 5) // 500 klines weigh 5
+}
+
+func (f *BinanceFutures) StreamCandles(symbol, interval string, onCandle func(Candle)) error {
+	urlStr := fmt.Sprintf("%s/ws/%s@kline_%s", f.env.BinanceFuturesWS, strings.ToLower(symbol), interval)
+	return streamBinanceKlines(urlStr, onCandle)
+}
+
+// PlaceSignalOrder sends a signal bot market order of qty coins
+func (f *BinanceFutures) PlaceSignalOrder(symbol, side string, qty float64) error {
+	endpoint := f.env.BinanceFuturesREST + "/fapi/v1/order"
+
+	qtyStr := strconv.FormatFloat(qty, 'f', constant.SymbolPrecisionMap[symbol][1], 64)
+
+	// The client id lets an unclear attempt be looked up instead of resent
+	clientID := fmt.Sprintf("sig-%s-%d", strings.ToLower(symbol), time.Now().UnixMilli())
+
+	var err error
+	for attempt := 0; attempt < retryAttempts; attempt++ {
+		params := url.Values{}
+		params.Set("symbol", strings.ToUpper(symbol))
+		params.Set("side", side) // "BUY" or "SELL"
+		params.Set("type", "MARKET")
+		params.Set("quantity", qtyStr)
+		params.Set("newClientOrderId", clientID)
+
+		var body []byte
+		body, err = binanceSigned(f.env, http.MethodPost, endpoint, params, PriorityOrder, 1)
+		if err == nil {
+			log.Println("Order response:", string(body))
+			return nil
+		}
+		exErr, ok := asExchangeError(err)
+		if ok && exErr.Answered() && !exErr.Transient() {
+			return err // turned down, retrying won't help
+		}
+
+		// The order may have gone through, only resend once it surely didn't
+		placed, lookupErr := f.orderExists(strings.ToUpper(symbol), clientID)
+		if lookupErr != nil {
+			log.Printf("Binance order %s lookup error: %v", clientID, lookupErr)
+			return err
+		}
+		if placed {
+			log.Printf("Binance order %s went through despite: %v", clientID, err)
+			return nil
+		}
+		resyncAfter(err)
+		wait := backoff(attempt, err)
+		log.Printf("Binance order %s failed (attempt %d), retrying in %v: %v", clientID, attempt+1, wait, err)
+		time.Sleep(wait)
+	}
+	return err
+}
+
+// orderExists asks whether an order with the client id was placed
+func (f *BinanceFutures) orderExists(symbol, clientID string) (bool, error) {
+	params := url.Values{}
+	params.Set("symbol", strings.ToUpper(symbol))
+	params.Set("origClientOrderId", clientID)
+
+	_, err := binanceSigned(f.env, http.MethodGet, f.env.BinanceFuturesREST+"/fapi/v1/order", params, PriorityOrder, 1)
+	if exErr, ok := asExchangeError(err); ok && exErr.Code == binanceOrderNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// binanceNetworkError classifies a request that got no answer. The limiter's
+// own errors already carry their kind.
+func binanceNetworkError(err error) error {
+	if _, ok := asExchangeError(err); ok {
+		return err
+	}
+	return &ExchangeError{Exchange: "binance", Kind: ErrNetwork, Err: err}
+}
+
+// binanceKlines fetches klines from env's spot or futures REST API, which
+// share the same row layout
+func binanceKlines(env Environment, url string, weight int) ([]Candle, error) {
+	var data [][]any
+	err := withRetry("Binance klines", func() error {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := binanceDo(req, PriorityMarketData, weight)
+		if err != nil {
+			return binanceNetworkError(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &ExchangeError{Exchange: "binance", Kind: ErrNetwork, Err: err}
+		}
+		if err := binanceError(resp.StatusCode, body); err != nil {
+			return err
+		}
+		return json.Unmarshal(body, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The last row is the candle still forming, Binance sends it along
+	now := binanceClock(env).Now()
+	var candles []Candle
+	for _, item := range data {
+		closeTime := time.UnixMilli(int64(item[6].(float64)))
+		if !closeTime.Before(now) {
+			continue
+		}
+		open := parseStringToFloat(item[1])
+		high := parseStringToFloat(item[2])
+		low := parseStringToFloat(item[3])
+		close := parseStringToFloat(item[4])
+		volume := parseStringToFloat(item[5])
+
+		candles = append(candles, Candle{
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     close,
+			Volume:    volume,
+			CloseTime: closeTime,
+			IsFinal:   true,
+		})
+	}
+	return candles, nil
+}
+
+// streamBinanceKlines passes every closed kline from a spot or futures
+// kline stream to onCandle until the connection drops
+func streamBinanceKlines(urlStr string, onCandle func(Candle)) error {
+	log.Println("Connecting to", urlStr)
+	c, _, err := websocket.DefaultDialer.Dial(urlStr, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var raw map[string]any
+		if err := json.Unmarshal(message, &raw); err != nil {
+			continue
+		}
+
+		kline, ok := raw["k"].(map[string]any)
+		if !ok || !kline["x"].(bool) {
+			continue
+		}
+
+		onCandle(Candle{
+			Open:      parseStringToFloat(kline["o"]),
+			High:      parseStringToFloat(kline["h"]),
+			Low:       parseStringToFloat(kline["l"]),
+			Close:     parseStringToFloat(kline["c"]),
+			Volume:    parseStringToFloat(kline["v"]),
+			CloseTime: time.UnixMilli(int64(kline["T"].(float64))),
+			IsFinal:   true,
+		})
+	}
+}
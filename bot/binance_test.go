@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// binanceTimeServer answers /fapi/v1/time off by skew from the local clock
+func binanceTimeServer(t *testing.T, skew time.Duration) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().Add(skew).UnixMilli())
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestBinanceClockPerEnvironment(t *testing.T) {
+	ahead := Environment{Name: "clocktest-ahead", BinanceFuturesREST: binanceTimeServer(t, 5*time.Second)}
+	behind := Environment{Name: "clocktest-behind", BinanceFuturesREST: binanceTimeServer(t, -5*time.Second)}
+
+	aheadClock, behindClock := binanceClock(ahead), binanceClock(behind)
+	if aheadClock == behindClock {
+		t.Fatal("two environments share a clock")
+	}
+	if binanceClock(ahead) != aheadClock {
+		t.Error("a second call made a new clock")
+	}
+	if offset := aheadClock.Offset(); offset < 4*time.Second {
+		t.Errorf("ahead offset %v", offset)
+	}
+	if offset := behindClock.Offset(); offset > -4*time.Second {
+		t.Errorf("behind offset %v", offset)
+	}
+}
+
+func TestBinanceSpotLimitTimeInForce(t *testing.T) {
+	var sent url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fapi/v1/time":
+			fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().UnixMilli())
+		case "/api/v3/order":
+			r.ParseForm()
+			sent = r.PostForm
+			fmt.Fprint(w, `{"orderId":7,"clientOrderId":"x","status":"EXPIRED","executedQty":"0","cummulativeQuoteQty":"0"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	spot := NewBinanceSpot(Environment{Name: "tiftest", BinanceFuturesREST: server.URL, BinanceSpotREST: server.URL})
+
+	for _, tc := range []struct{ tif, want string }{{"IOC", "IOC"}, {"", "GTC"}} {
+		_, err := spot.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "Sell", Type: "Limit", Qty: "0.01", Price: "64000", TimeInForce: tc.tif, LinkID: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sent.Get("timeInForce"); got != tc.want {
+			t.Errorf("time in force %q sent as %q, want %q", tc.tif, got, tc.want)
+		}
+	}
+}
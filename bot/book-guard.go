@@ -0,0 +1,370 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Order Book Guard
+////////////////////////////////////////////////////////////
+
+// BookAction decides what happens to a market order the book can't take
+type BookAction string
+
+const (
+	BookDelay BookAction = "delay" // wait up to bookDelay for the book to refill
+	BookSplit BookAction = "split" // send it in smaller pieces
+	BookLimit BookAction = "limit" // send an IOC limit at the worst acceptable price
+)
+
+const (
+	bookStale      = 10 * time.Second // an older book says nothing about now
+	bookDelay      = 30 * time.Second
+	bookSplitMax   = 5
+	bookSplitPause = 2 * time.Second
+)
+
+var (
+	errNoBookFill = errors.New("nothing filled within the slippage limit")
+	errBookHeld   = errors.New("held back until the book refills")
+)
+
+func ParseBookAction(s string) (BookAction, error) {
+	switch a := BookAction(strings.ToLower(strings.TrimSpace(s))); a {
+	case "":
+		return BookDelay, nil
+	case BookDelay, BookSplit, BookLimit:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown order book action %q, use delay, split or limit", s)
+}
+
+// BookGuard limits the spread and the estimated slippage market orders may
+// face, each off when 0
+type BookGuard struct {
+	MaxSpread   float64
+	MaxSlippage float64
+	Action      BookAction
+}
+
+func (g BookGuard) Enabled() bool {
+	return g.MaxSpread > 0 || g.MaxSlippage > 0
+}
+
+func (g BookGuard) String() string {
+	if !g.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("spread ≤ %g%%, slippage ≤ %g%%, else %s", g.MaxSpread, g.MaxSlippage, g.Action)
+}
+
+// exceeded is why the book can't take the order as it stands, if anything
+func (g BookGuard) exceeded(est BookEstimate) string {
+	if g.MaxSpread > 0 && est.Spread > g.MaxSpread {
+		return fmt.Sprintf("spread %.3f%% over %g%%", est.Spread, g.MaxSpread)
+	}
+	if g.MaxSlippage > 0 && !est.Complete {
+		return "the visible book can't fill the order"
+	}
+	if g.MaxSlippage > 0 && est.Slippage > g.MaxSlippage {
+		return fmt.Sprintf("estimated slippage %.3f%% over %g%%", est.Slippage, g.MaxSlippage)
+	}
+	return ""
+}
+
+// startBookFeed keeps b.Book live when the guard is on and the exchange
+// streams a book
+func (b *DCABot) startBookFeed() {
+	if !b.BookGuard.Enabled() {
+		return
+	}
+	feed, ok := b.Exchange.(OrderBookFeed)
+	if !ok {
+		log.Printf("%s has no order book feed, market orders go out unchecked", b.Exchange.Name())
+		return
+	}
+
+	b.Book = NewOrderBook()
+	go func() {
+		for {
+			err := feed.StreamOrderBook(b.Symbol, b.Book)
+			log.Printf("%s order book WS disconnected: %v. Reconnecting...", b.Exchange.Name(), err)
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+// estimate sizes req against the live book, false when there is no fresh book
+func (b *DCABot) estimate(req OrderRequest) (BookEstimate, bool) {
+	if time.Since(b.Book.Updated()) > bookStale {
+		return BookEstimate{}, false
+	}
+	return b.Book.Estimate(req.Side, parseStringToFloat(req.Qty), parseStringToFloat(req.QuoteQty))
+}
+
+// placeMarket sends a market order through the guard: straight away when the
+// book can take it, otherwise held for later ticks, split or as an IOC
+// limit. book takes the fills of split pieces that go out on later ticks.
+// When it returns errFillPending the order went out, possibly as the first
+// piece of a split, and its fill is still to be booked.
+func (b *DCABot) placeMarket(intent *OrderIntent, req OrderRequest, book func(price, qty, value float64)) (*Order, error) {
+	if b.Book == nil {
+		return b.submitOrder(intent, req)
+	}
+	est, ok := b.estimate(req)
+	if !ok {
+		log.Printf("%s order book unavailable, %s goes out unchecked", b.Symbol, req.Side)
+		return b.submitOrder(intent, req)
+	}
+	reason := b.BookGuard.exceeded(est)
+	if reason == "" {
+		b.bookHeldSince = time.Time{}
+		return b.guardedFill(intent, req, est)
+	}
+
+	switch b.BookGuard.Action {
+	case BookLimit:
+		log.Printf("%s %s held back: %s", b.Symbol, req.Side, reason)
+		return b.placeIOC(intent, req, est)
+	case BookSplit:
+		log.Printf("%s %s held back: %s", b.Symbol, req.Side, reason)
+		return b.placeSplit(intent, req, est, book)
+	}
+
+	// The trigger fires again on the next ticks, the order waits for the
+	// book until bookDelay has passed and then goes out anyway
+	if b.bookHeldSince.IsZero() || b.bookHeldSide != req.Side {
+		b.bookHeldSince = time.Now()
+		b.bookHeldSide = req.Side
+		log.Printf("%s %s held back up to %s: %s", b.Symbol, req.Side, bookDelay, reason)
+		return nil, errBookHeld
+	}
+	if time.Since(b.bookHeldSince) < bookDelay {
+		return nil, errBookHeld
+	}
+	log.Printf("%s %s still held back after %s (%s), sending anyway", b.Symbol, req.Side, bookDelay, reason)
+	b.bookHeldSince = time.Time{}
+	return b.guardedFill(intent, req, est)
+}
+
+// guardedFill places the order and logs the slippage of its fill against the
+// estimate. The create call only returns the id on some exchanges, so the
+// order is looked up once; one still open, or not found yet, comes back as
+// errFillPending for reconcileIntents to book, one that closed unfilled as
+// errNoBookFill.
+func (b *DCABot) guardedFill(intent *OrderIntent, req OrderRequest, est BookEstimate) (*Order, error) {
+	order, err := b.submitOrder(intent, req)
+	if err != nil {
+		return nil, err
+	}
+	if order.FilledQty == 0 {
+		if o, err := b.Exchange.GetOrder(b.Symbol, order.ID); err == nil {
+			order = o
+		}
+	}
+	if order.FilledQty == 0 {
+		if order.Open() || order.Status == "" {
+			intent.estimate = &est
+			return nil, errFillPending // the intent stays for reconcileIntents
+		}
+		b.finishIntent(intent.LinkID)
+		return nil, errNoBookFill
+	}
+	b.logSlippage(req.Side, est, order)
+	return order, nil
+}
+
+func (b *DCABot) logSlippage(side string, est BookEstimate, order *Order) {
+	if order.FilledQty == 0 || est.Best == 0 {
+		return
+	}
+	avg := order.Value() / order.FilledQty
+	realised := (avg - est.Best) / est.Best * 100
+	if side == "Sell" {
+		realised = -realised
+	}
+	log.Printf("%s %s fill %.6f @ %.4f: slippage estimated %.3f%%, realised %.3f%% (spread %.3f%%)",
+		b.Symbol, side, order.FilledQty, avg, est.Slippage, realised, est.Spread)
+}
+
+// placeIOC turns the market order into an immediate-or-cancel limit at the
+// worst price the slippage limit allows, whatever doesn't fill is dropped
+func (b *DCABot) placeIOC(intent *OrderIntent, req OrderRequest, est BookEstimate) (*Order, error) {
+	if err := b.loadInstrument(); err != nil {
+		return nil, err
+	}
+	limit := est.Best * (1 + b.BookGuard.MaxSlippage/100)
+	price := floorToStep(limit, b.instrument.TickSize)
+	if req.Side == "Sell" {
+		limit = est.Best * (1 - b.BookGuard.MaxSlippage/100)
+		price = ceilToStep(limit, b.instrument.TickSize)
+	}
+	if req.QuoteQty != "" {
+		req.Qty = floorToStep(parseStringToFloat(req.QuoteQty)/limit, b.instrument.QtyStep)
+		req.QuoteQty = ""
+	}
+	req.Type = "Limit"
+	req.Price = price
+	req.TimeInForce = "IOC"
+	intent.Qty = req.Qty
+
+	log.Printf("%s %s as IOC limit %s @ %s", b.Symbol, req.Side, req.Qty, price)
+	return b.guardedFill(intent, req, est)
+}
+
+// splitOrder is a split market order, its pieces go out bookSplitPause
+// apart on the ticks that follow so the book can refill. done finishes the
+// order the split belongs to once its last piece is booked.
+type splitOrder struct {
+	req       OrderRequest
+	kind      string
+	price     float64 // the trigger price, kept on the piece intents
+	pieces    int
+	sent      int
+	left      float64
+	leftQuote float64
+	next      time.Time
+	book      func(price, qty, value float64)
+	done      func()
+}
+
+// piece sizes the next piece, the last one takes whatever is left
+func (s *splitOrder) piece(step float64) OrderRequest {
+	piece := s.req
+	last := s.sent == s.pieces-1
+	switch {
+	case s.req.QuoteQty != "" && last:
+		piece.QuoteQty = fmt.Sprintf("%.2f", s.leftQuote)
+	case s.req.QuoteQty != "":
+		piece.QuoteQty = fmt.Sprintf("%.2f", s.leftQuote/float64(s.pieces-s.sent))
+	case last:
+		piece.Qty = floorToStep(s.left, step)
+	default:
+		piece.Qty = floorToStep(s.left/float64(s.pieces-s.sent), step)
+	}
+	s.leftQuote -= parseStringToFloat(piece.QuoteQty)
+	s.left -= parseStringToFloat(piece.Qty)
+	s.sent++
+	return piece
+}
+
+// placeSplit sends the first piece of an order split small enough for the
+// slippage limit and leaves the rest to continueSplit
+func (b *DCABot) placeSplit(intent *OrderIntent, req OrderRequest, est BookEstimate, book func(price, qty, value float64)) (*Order, error) {
+	n := bookSplitMax
+	if b.BookGuard.MaxSlippage > 0 && est.Complete {
+		n = int(math.Ceil(est.Slippage / b.BookGuard.MaxSlippage))
+	}
+	n = max(2, min(n, bookSplitMax))
+
+	kind := "S"
+	if req.Side == b.entrySide() {
+		kind = "B"
+	}
+	log.Printf("%s %s split into %d orders", b.Symbol, req.Side, n)
+
+	split := &splitOrder{
+		req:       req,
+		kind:      kind,
+		price:     intent.Price,
+		pieces:    n,
+		left:      parseStringToFloat(req.Qty),
+		leftQuote: parseStringToFloat(req.QuoteQty),
+		book:      book,
+	}
+	first := split.piece(b.qtyStep())
+	intent.Qty = first.Qty
+	if first.QuoteQty != "" {
+		intent.Qty = first.QuoteQty
+	}
+	// The rest follows on the next ticks, once a pending first piece is booked
+	order, err := b.guardedFill(intent, first, est)
+	if err == nil || errors.Is(err, errFillPending) {
+		split.next = time.Now().Add(bookSplitPause)
+		b.split = split
+	}
+	return order, err
+}
+
+// continueSplit sends the next piece of a split order once the pause is
+// over and books its fill, finishing the order after the last one. Nothing
+// else trades until then.
+func (b *DCABot) continueSplit(token string) {
+	s := b.split
+	if time.Now().Before(s.next) {
+		return
+	}
+	piece := s.piece(b.qtyStep())
+	last := s.sent == s.pieces
+	if last {
+		b.split = nil
+	} else {
+		s.next = time.Now().Add(bookSplitPause)
+	}
+
+	est, _ := b.estimate(piece)
+	qty := piece.Qty
+	if piece.QuoteQty != "" {
+		qty = piece.QuoteQty
+	}
+	intent := &OrderIntent{LinkID: b.nextOrderLinkID(s.kind), Side: piece.Side, Qty: qty, Price: s.price}
+	order, err := b.guardedFill(intent, piece, est)
+	if errors.Is(err, errFillPending) {
+		done := s.done
+		if !last {
+			done = nil // still the split's to run
+		}
+		b.bookLater(intent.LinkID, s.book, done)
+		b.handleOrderError(piece.Side, err, token)
+		return
+	}
+	if err != nil {
+		b.split = nil
+		log.Printf("%s split order %d/%d failed, keeping the %d that went out: %v", b.Symbol, s.sent, s.pieces, s.sent-1, err)
+		b.handleOrderError(piece.Side, err, token)
+		return
+	}
+
+	value := order.Value()
+	price := value / order.FilledQty
+	s.book(price, order.FilledQty, value)
+	b.finishIntent(intent.LinkID)
+	b.refreshAccount()
+
+	message := fmt.Sprintf("🧩 %s %s %s piece %d/%d\nPrice: %.4f\nQty: %.6f",
+		strings.ToUpper(b.Exchange.Name()), b.Symbol, piece.Side, s.sent, s.pieces, price, order.FilledQty)
+	sendTelegramMessage(token, message)
+
+	if s.kind == "B" {
+		b.syncTakeProfit(token) // an exit syncs once it is done, a resting sell would lock its coins
+	}
+	if last && s.done != nil {
+		s.done()
+	}
+}
+
+func (b *DCABot) qtyStep() float64 {
+	if err := b.loadInstrument(); err != nil {
+		return 0
+	}
+	return b.instrument.QtyStep
+}
+
+// bookStatus is the order book line for /status
+func (b *DCABot) bookStatus() string {
+	if !b.BookGuard.Enabled() {
+		return ""
+	}
+	status := "Book guard: " + b.BookGuard.String()
+	if b.Book != nil {
+		if est, ok := b.estimate(OrderRequest{Side: b.entrySide(), QuoteQty: fmt.Sprintf("%.2f", b.nextOrderUSDT()*b.leverage())}); ok {
+			status += fmt.Sprintf("\nSpread %.3f%%, next buy slippage %.3f%%", est.Spread, est.Slippage)
+		}
+	}
+	return status
+}
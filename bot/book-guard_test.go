@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeSpot fills every order at fillPrice, or leaves it open until fill is
+// called when pending is set
+type fakeSpot struct {
+	fillPrice float64
+	pending   bool
+	orders    map[string]*Order
+	sent      []OrderRequest
+}
+
+func newFakeSpot(fillPrice float64) *fakeSpot {
+	return &fakeSpot{fillPrice: fillPrice, orders: map[string]*Order{}}
+}
+
+func (f *fakeSpot) Name() string { return "fake" }
+
+func (f *fakeSpot) Instrument(symbol string) (*Instrument, error) {
+	return &Instrument{TickSize: 0.01, QtyStep: 0.001, MinOrderQty: 0.001, MinOrderAmt: 1}, nil
+}
+
+func (f *fakeSpot) PlaceOrder(req OrderRequest) (*Order, error) {
+	f.sent = append(f.sent, req)
+	order := &Order{ID: fmt.Sprint(len(f.sent)), LinkID: req.LinkID, Status: OrderNew, Qty: parseStringToFloat(req.Qty)}
+	f.orders[order.ID] = order
+	if !f.pending {
+		f.fill(order)
+	}
+	placed := *order
+	placed.Status, placed.FilledQty, placed.FilledValue, placed.AvgPrice = "", 0, 0, 0 // only the id comes back
+	return &placed, nil
+}
+
+func (f *fakeSpot) fill(order *Order) {
+	order.Status = OrderFilled
+	order.FilledQty = order.Qty
+	order.AvgPrice = f.fillPrice
+}
+
+func (f *fakeSpot) fillAll() {
+	for _, o := range f.orders {
+		if o.Open() {
+			f.fill(o)
+		}
+	}
+}
+
+func (f *fakeSpot) soldQty() float64 {
+	qty := 0.0
+	for _, o := range f.orders {
+		qty += o.FilledQty
+	}
+	return qty
+}
+
+func (f *fakeSpot) AmendOrder(symbol, orderID, qty, price string) error { return errAmendUnsupported }
+func (f *fakeSpot) CancelOrder(symbol, orderID string) error            { return nil }
+
+func (f *fakeSpot) GetOrder(symbol, orderID string) (*Order, error) {
+	o, ok := f.orders[orderID]
+	if !ok {
+		return nil, errOrderNotFound
+	}
+	found := *o
+	return &found, nil
+}
+
+func (f *fakeSpot) GetOrderByLinkID(symbol, linkID string) (*Order, error) {
+	for _, o := range f.orders {
+		if o.LinkID == linkID {
+			found := *o
+			return &found, nil
+		}
+	}
+	return nil, errOrderNotFound
+}
+
+func (f *fakeSpot) StreamTrades(symbol string, onPrice func(price float64)) error { return nil }
+
+// quietTelegram sends the alerts of a test to a local server
+func quietTelegram(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	saved := telegramAPI
+	telegramAPI = server.URL
+	t.Cleanup(func() {
+		telegramAPI = saved
+		server.Close()
+	})
+}
+
+// holdingBot holds 1 coin bought at 100 and takes profit at +2% through a
+// book guard that splits sells
+func holdingBot(exchange SpotExchange) *DCABot {
+	b := NewDCABot(exchange, "BTCUSDT", 900, 1, 2, 24)
+	b.Started = true
+	b.LastBuyPrice = 100
+	b.LastBuyTime = time.Now()
+	b.Records = []DCARecord{{BuyNumber: 1, Price: 100, USDTSpent: 100, AmountBought: 1, TotalHoldings: 1}}
+	b.BookGuard = BookGuard{MaxSlippage: 0.1, Action: BookSplit}
+	b.Book = NewOrderBook()
+	b.Book.apply(true,
+		[][2]string{{"103", "0.3"}, {"102.9", "0.3"}, {"102.5", "2"}},
+		[][2]string{{"103.1", "5"}})
+	return b
+}
+
+func TestSplitSellBooksAllPieces(t *testing.T) {
+	quietTelegram(t)
+	exchange := newFakeSpot(103)
+	b := holdingBot(exchange)
+
+	b.OnPrice(103, "")
+	if b.split == nil {
+		t.Fatal("the sell was not split")
+	}
+	pieces := b.split.pieces
+	if b.TPLevel != 0 || len(b.Records) == 0 {
+		t.Fatalf("take profit finished after the first piece: level %d, %d records", b.TPLevel, len(b.Records))
+	}
+
+	for i := 0; b.split != nil && i < 10; i++ {
+		b.split.next = time.Time{} // skip the pause
+		b.Book.apply(false, nil, nil)
+		b.OnPrice(103, "")
+	}
+	if b.split != nil {
+		t.Fatal("split never finished")
+	}
+
+	if len(exchange.sent) != pieces {
+		t.Errorf("%d orders sent for %d pieces", len(exchange.sent), pieces)
+	}
+	if sold := exchange.soldQty(); math.Abs(sold-1) > 1e-9 {
+		t.Errorf("sold %.6f, want the whole coin", sold)
+	}
+	if b.TPLevel != 1 || b.Records != nil {
+		t.Errorf("take profit not finished: level %d, records %v", b.TPLevel, b.Records)
+	}
+	if math.Abs(b.TotalUSDT-1003) > 1e-6 || math.Abs(b.RealizedPNL-3) > 1e-6 {
+		t.Errorf("budget %.4f and PNL %.4f, want 1003 and 3", b.TotalUSDT, b.RealizedPNL)
+	}
+}
+
+func TestPendingSellBookedOnLaterTick(t *testing.T) {
+	quietTelegram(t)
+	exchange := newFakeSpot(103)
+	exchange.pending = true
+	b := holdingBot(exchange)
+	b.BookGuard = BookGuard{MaxSpread: 1, Action: BookDelay} // the book takes it in one
+
+	start := time.Now()
+	b.OnPrice(103, "")
+	if time.Since(start) > time.Second {
+		t.Errorf("OnPrice waited %v for the fill", time.Since(start))
+	}
+	if len(b.Intents) != 1 || b.TPLevel != 0 || len(b.Records) == 0 {
+		t.Fatalf("pending sell booked early: %d intents, level %d", len(b.Intents), b.TPLevel)
+	}
+
+	// Nothing trades while the fill is unknown
+	b.OnPrice(103, "")
+	if len(exchange.sent) != 1 {
+		t.Fatalf("%d orders sent while one was pending", len(exchange.sent))
+	}
+
+	exchange.fillAll()
+	b.lastReconcile = time.Time{}
+	b.OnPrice(103, "")
+	if len(b.Intents) != 0 || b.TPLevel != 1 || b.Records != nil {
+		t.Errorf("fill not booked: %d intents, level %d, records %v", len(b.Intents), b.TPLevel, b.Records)
+	}
+	if math.Abs(b.TotalUSDT-1003) > 1e-6 {
+		t.Errorf("budget %.4f, want 1003", b.TotalUSDT)
+	}
+}
+
+func TestStopLossSkipsBookGuard(t *testing.T) {
+	quietTelegram(t)
+	exchange := newFakeSpot(90)
+	b := holdingBot(exchange)
+	b.BookGuard = BookGuard{MaxSlippage: 0.1, Action: BookDelay} // would hold a normal sell
+	b.StopLossPercent = 5
+	b.StopLossAction = ProtectSellAll
+	b.Book.apply(true, [][2]string{{"90", "0.1"}, {"85", "5"}}, [][2]string{{"90.1", "5"}})
+
+	b.OnPrice(90, "")
+	if len(exchange.sent) != 1 {
+		t.Fatalf("%d orders sent, want the stop loss sell", len(exchange.sent))
+	}
+	if !b.ProtectHit[protectStopLoss] || b.Records != nil || !b.BuysPaused {
+		t.Errorf("stop loss not finished: hit %v, records %v, buys paused %v", b.ProtectHit[protectStopLoss], b.Records, b.BuysPaused)
+	}
+}
@@ -1,27 +1,20 @@
 package bot
 
 import (
-	"dca-bot/config"
 	"dca-bot/constant"
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 // Candle represents a Binance kline/candle message
@@ -49,14 +42,17 @@ var (
 	stopLossPercent float64
 	numOfWin        = 0
 	numOfLose       = 0
+	httpClient      = &http.Client{Timeout: 10 * time.Second}
 )
 
-// Bot runs the trading bot on given symbol, interval and stop loss percent
-func Bot(symbol, interval, token string, slPercent float64) {
+// Bot runs the trading bot on given symbol, interval and stop loss percent,
+// reading candles from feed
+func Bot(feed CandleFeed, symbol, interval, token string, slPercent float64) {
 	stopLossPercent = slPercent
+	signalTrader, _ = feed.(SignalOrderer)
 
 	// Fetch historical candles
-	history, err := fetchHistoricalCandles(strings.ToUpper(symbol), interval)
+	history, err := feed.Candles(strings.ToUpper(symbol), interval, 500)
 	if err != nil {
 		log.Fatal("Error fetching historical candles:", err)
 	}
@@ -75,112 +71,37 @@ func Bot(symbol, interval, token string, slPercent float64) {
 	sendTelegramMessage(token, msg)
 
 	// Start WebSocket
-	go startWebSocket(strings.ToLower(symbol), interval, token)
+	go startWebSocket(feed, strings.ToLower(symbol), interval, token)
 
 	waitForShutdown()
 }
 
-func fetchHistoricalCandles(symbol, interval string) ([]Candle, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/klines?symbol=%s&interval=%s", symbol, interval)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var data [][]any
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
-	}
-
-	var candles []Candle
-	for _, item := range data {
-		open := parseStringToFloat(item[1])
-		high := parseStringToFloat(item[2])
-		low := parseStringToFloat(item[3])
-		close := parseStringToFloat(item[4])
-		volume := parseStringToFloat(item[5])
-		closeTime := time.UnixMilli(int64(item[6].(float64)))
-
-		candles = append(candles, Candle{
-			Open:      open,
-			High:      high,
-			Low:       low,
-			Close:     close,
-			Volume:    volume,
-			CloseTime: closeTime,
-			IsFinal:   true,
-		})
-	}
-	return candles, nil
-}
-
 func parseStringToFloat(s any) float64 {
 	val, _ := strconv.ParseFloat(s.(string), 64)
 	return val
 }
 
-func startWebSocket(symbol, interval, token string) {
-	urlStr := fmt.Sprintf("wss://fstream.binance.com/ws/%s@kline_%s", symbol, interval)
-
+func startWebSocket(feed CandleFeed, symbol, interval, token string) {
 	for {
-		log.Println("Connecting to", urlStr)
-		c, _, err := websocket.DefaultDialer.Dial(urlStr, nil)
-		if err != nil {
-			log.Println("WebSocket dial error:", err)
-			time.Sleep(5 * time.Second)
-			continue
-		}
+		err := feed.StreamCandles(symbol, interval, func(candle Candle) {
+			spikeUpPerc := ((candle.High - candle.Open) / candle.Open * 100)
+			spikeDownPerc := ((candle.Low - candle.Open) / candle.Open * 100)
+			a := constant.PercentageMap[interval]
+
+			if spikeUpPerc >= a {
+				msg := fmt.Sprintf("⚠️ Sudden PUMP detected!\nSymbol: %s\nHigh: %.4f\nOpen: %.4f\nChange: +%.2f%%", symbol, candle.High, candle.Open, spikeUpPerc)
+				sendTelegramMessage(token, msg)
+			}
 
-		func(conn *websocket.Conn) {
-			defer conn.Close()
-
-			for {
-				_, message, err := conn.ReadMessage()
-				if err != nil {
-					log.Println("Read error:", err)
-					return // exit inner loop to reconnect
-				}
-
-				var raw map[string]any
-				if err := json.Unmarshal(message, &raw); err != nil {
-					continue
-				}
-
-				kline, ok := raw["k"].(map[string]any)
-				if !ok || !kline["x"].(bool) {
-					continue
-				}
-
-				candle := Candle{
-					Open:      parseStringToFloat(kline["o"]),
-					High:      parseStringToFloat(kline["h"]),
-					Low:       parseStringToFloat(kline["l"]),
-					Close:     parseStringToFloat(kline["c"]),
-					Volume:    parseStringToFloat(kline["v"]),
-					CloseTime: time.UnixMilli(int64(kline["T"].(float64))),
-					IsFinal:   true,
-				}
-
-				spikeUpPerc := ((candle.High - candle.Open) / candle.Open * 100)
-				spikeDownPerc := ((candle.Low - candle.Open) / candle.Open * 100)
-				a := constant.PercentageMap[interval]
-
-				if spikeUpPerc >= a {
-					msg := fmt.Sprintf("⚠️ Sudden PUMP detected!\nSymbol: %s\nHigh: %.4f\nOpen: %.4f\nChange: +%.2f%%", symbol, candle.High, candle.Open, spikeUpPerc)
-					sendTelegramMessage(token, msg)
-				}
-
-				if spikeDownPerc <= -a {
-					msg := fmt.Sprintf("⚠️ Sudden DUMP detected!\nSymbol: %s\nLow: %.4f\nOpen: %.4f\nChange: %.2f%%", symbol, candle.Low, candle.Open, spikeDownPerc)
-					sendTelegramMessage(token, msg)
-				}
-
-				processCandle(candle, symbol, token)
+			if spikeDownPerc <= -a {
+				msg := fmt.Sprintf("⚠️ Sudden DUMP detected!\nSymbol: %s\nLow: %.4f\nOpen: %.4f\nChange: %.2f%%", symbol, candle.Low, candle.Open, spikeDownPerc)
+				sendTelegramMessage(token, msg)
 			}
-		}(c)
 
-		log.Println("WebSocket disconnected. Reconnecting in 5s...")
+			processCandle(candle, symbol, token)
+		})
+
+		log.Println("WebSocket disconnected:", err, "Reconnecting in 5s...")
 		time.Sleep(5 * time.Second)
 	}
 }
@@ -385,44 +306,17 @@ func processCandle(c Candle, symbol, token string) {
 	}
 }
 
-func placeOrder(symbol string, side string) {
-	endpoint := "https://fapi.binance.com/fapi/v1/order"
-	timestamp := time.Now().UnixMilli()
-
-	// Convert quantity to string with 4 decimals
-	qtyStr := strconv.FormatFloat(constant.QuantityMap[symbol], 'f', constant.SymbolPrecisionMap[symbol][1], 64)
-
-	params := url.Values{}
-	params.Set("symbol", symbol)
-	params.Set("side", side) // "BUY" or "SELL"
-	params.Set("type", "MARKET")
-	params.Set("quantity", qtyStr)
-	params.Set("timestamp", strconv.FormatInt(timestamp, 10))
+// signalTrader places the running signal bot's orders, nil when its feed
+// can't trade
+var signalTrader SignalOrderer
 
-	// Sign the query
-	queryString := params.Encode()
-	signature := sign(queryString, config.BinanceApiSecret)
-	params.Set("signature", signature)
-
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBufferString(params.Encode()))
-	if err != nil {
-		log.Println("Error creating order request:", err)
-		return
+// placeOrder sends a signal market order of the configured size through the
+// exchange the candles come from
+func placeOrder(symbol string, side string) error {
+	if signalTrader == nil {
+		return fmt.Errorf("%s: this candle feed can't place orders", symbol)
 	}
-
-	req.Header.Set("X-MBX-APIKEY", config.BinanceApiKey)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error placing order:", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Println("Order response:", string(body))
+	return signalTrader.PlaceSignalOrder(symbol, side, constant.QuantityMap[symbol])
 }
 
 // sign generates HMAC-SHA256 signature
@@ -0,0 +1,609 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+	"github.com/gorilla/websocket"
+)
+
+// BybitExchange trades one Bybit category (spot or linear) through the SDK
+type BybitExchange struct {
+	client   *bybit.Client
+	env      Environment
+	category string
+}
+
+func NewBybitExchange(client *bybit.Client, env Environment, category string) *BybitExchange {
+	return &BybitExchange{client: client, env: env, category: category}
+}
+
+func (e *BybitExchange) Name() string {
+	return "bybit"
+}
+
+type bybitOrder struct {
+	OrderID      string `json:"orderId"`
+	OrderLinkID  string `json:"orderLinkId"`
+	OrderStatus  string `json:"orderStatus"`
+	Price        string `json:"price"`
+	Qty          string `json:"qty"`
+	CumExecQty   string `json:"cumExecQty"`
+	CumExecValue string `json:"cumExecValue"`
+	AvgPrice     string `json:"avgPrice"`
+	CreatedTime  string `json:"createdTime"`
+}
+
+func (o bybitOrder) toOrder() *Order {
+	status := o.OrderStatus
+	switch status {
+	case "Untriggered", "Created":
+		status = OrderNew
+	case "Deactivated", "PartiallyFilledCanceled":
+		status = OrderCancelled
+	}
+	created, _ := strconv.ParseInt(o.CreatedTime, 10, 64)
+
+	return &Order{
+		ID:          o.OrderID,
+		LinkID:      o.OrderLinkID,
+		Status:      status,
+		Price:       parseStringToFloat(o.Price),
+		Qty:         parseStringToFloat(o.Qty),
+		FilledQty:   parseStringToFloat(o.CumExecQty),
+		FilledValue: parseStringToFloat(o.CumExecValue),
+		AvgPrice:    parseStringToFloat(o.AvgPrice),
+		Created:     time.UnixMilli(created),
+	}
+}
+
+// decodeBybitResult classifies a failed call or non-zero retCode and
+// unmarshals the untyped result into v
+func decodeBybitResult(res *bybit.ServerResponse, err error, v any) error {
+	if err != nil {
+		return wrapBybitErr(err)
+	}
+	if err := bybitError(res); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(res.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (e *BybitExchange) Instrument(symbol string) (*Instrument, error) {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+	}
+	var data struct {
+		List []struct {
+			LotSizeFilter struct {
+				BasePrecision    string `json:"basePrecision"`
+				QtyStep          string `json:"qtyStep"`
+				MinOrderQty      string `json:"minOrderQty"`
+				MinOrderAmt      string `json:"minOrderAmt"`
+				MinNotionalValue string `json:"minNotionalValue"`
+			} `json:"lotSizeFilter"`
+			PriceFilter struct {
+				TickSize string `json:"tickSize"`
+			} `json:"priceFilter"`
+		} `json:"list"`
+	}
+	err := withRetry("Bybit instrument info", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).GetInstrumentInfo(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data.List) == 0 {
+		return nil, fmt.Errorf("instrument %s not found", symbol)
+	}
+
+	item := data.List[0]
+	step := item.LotSizeFilter.BasePrecision
+	if step == "" {
+		step = item.LotSizeFilter.QtyStep // linear contracts
+	}
+	minAmt := item.LotSizeFilter.MinOrderAmt
+	if minAmt == "" {
+		minAmt = item.LotSizeFilter.MinNotionalValue
+	}
+
+	return &Instrument{
+		TickSize:    parseStringToFloat(item.PriceFilter.TickSize),
+		QtyStep:     parseStringToFloat(step),
+		MinOrderQty: parseStringToFloat(item.LotSizeFilter.MinOrderQty),
+		MinOrderAmt: parseStringToFloat(minAmt),
+	}, nil
+}
+
+// PlaceOrder sends a single attempt; retrying is up to the caller, which
+// knows how to look the order up by its link id first
+func (e *BybitExchange) PlaceOrder(req OrderRequest) (*Order, error) {
+	params := map[string]interface{}{
+		"category":    e.category,
+		"symbol":      req.Symbol,
+		"side":        req.Side,
+		"orderType":   req.Type,
+		"qty":         req.Qty,
+		"orderLinkId": req.LinkID,
+	}
+	if req.QuoteQty != "" {
+		params["qty"] = req.QuoteQty
+		params["marketUnit"] = "quoteCoin"
+	}
+	if req.Type == "Limit" {
+		params["price"] = req.Price
+		params["timeInForce"] = "GTC"
+		if req.TimeInForce != "" {
+			params["timeInForce"] = req.TimeInForce
+		}
+	}
+	if req.ReduceOnly {
+		params["reduceOnly"] = true
+	}
+
+	res, err := e.client.NewUtaBybitServiceWithParams(params).PlaceOrder(context.Background())
+	var order bybitOrder
+	if err := decodeBybitResult(res, err, &order); err != nil {
+		return nil, err
+	}
+	return order.toOrder(), nil
+}
+
+func (e *BybitExchange) AmendOrder(symbol, orderID, qty, price string) error {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+		"orderId":  orderID,
+		"qty":      qty,
+		"price":    price,
+	}
+	return withRetry("Bybit amend", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).AmendOrder(context.Background())
+		return decodeBybitResult(res, err, nil)
+	})
+}
+
+func (e *BybitExchange) CancelOrder(symbol, orderID string) error {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+		"orderId":  orderID,
+	}
+	return withRetry("Bybit cancel", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).CancelOrder(context.Background())
+		return decodeBybitResult(res, err, nil)
+	})
+}
+
+func (e *BybitExchange) GetOrder(symbol, orderID string) (*Order, error) {
+	return e.fetchOrder(symbol, "orderId", orderID)
+}
+
+func (e *BybitExchange) GetOrderByLinkID(symbol, linkID string) (*Order, error) {
+	return e.fetchOrder(symbol, "orderLinkId", linkID)
+}
+
+// fetchOrder looks up an order by orderId or orderLinkId, falling back to
+// history once it has left the open order list
+func (e *BybitExchange) fetchOrder(symbol, idKey, id string) (*Order, error) {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+		idKey:      id,
+	}
+
+	var data struct {
+		List []bybitOrder `json:"list"`
+	}
+
+	err := withRetry("Bybit open orders", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).GetOpenOrders(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data.List) > 0 {
+		return data.List[0].toOrder(), nil
+	}
+
+	err = withRetry("Bybit order history", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).GetOrderHistory(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data.List) == 0 {
+		return nil, errOrderNotFound
+	}
+	return data.List[0].toOrder(), nil
+}
+
+func (e *BybitExchange) StreamTrades(symbol string, onPrice func(price float64)) error {
+	return e.stream("publicTrade."+symbol, func(data json.RawMessage) {
+		// Bybit V5 Public Trade data structure
+		var trades []struct {
+			Price string `json:"p"`
+		}
+		if err := json.Unmarshal(data, &trades); err != nil || len(trades) == 0 {
+			return
+		}
+		price, _ := strconv.ParseFloat(trades[0].Price, 64)
+		onPrice(price)
+	})
+}
+
+// stream subscribes to one public topic of the category and hands each data
+// payload to onData until the connection drops
+func (e *BybitExchange) stream(topic string, onData func(json.RawMessage)) error {
+	return e.streamTyped(topic, func(_ string, data json.RawMessage) { onData(data) })
+}
+
+// streamTyped is stream for topics whose messages are either a "snapshot"
+// or a "delta" on top of it
+func (e *BybitExchange) streamTyped(topic string, onData func(typ string, data json.RawMessage)) error {
+	wsURL := e.env.BybitSpotWS
+	if e.category == "linear" {
+		wsURL = e.env.BybitLinearWS
+	}
+	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// 1. Subscribe to the topic
+	sub := map[string]interface{}{
+		"op":   "subscribe",
+		"args": []string{topic},
+	}
+	if err := c.WriteJSON(sub); err != nil {
+		return err
+	}
+
+	// 2. Start Heartbeat (Ping) every 20s
+	go func() {
+		ticker := time.NewTicker(20 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := c.WriteJSON(map[string]string{"op": "ping"}); err != nil {
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("✅ Bybit WS Connected for %s\n", topic)
+
+	for {
+		var msg struct {
+			Topic string          `json:"topic"`
+			Type  string          `json:"type"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := c.ReadJSON(&msg); err != nil {
+			return err
+		}
+		if msg.Topic == topic && len(msg.Data) > 0 {
+			onData(msg.Type, msg.Data)
+		}
+	}
+}
+
+// StreamOrderBook keeps book in sync with the 50 level book of the symbol
+func (e *BybitExchange) StreamOrderBook(symbol string, book *OrderBook) error {
+	return e.streamTyped("orderbook.50."+symbol, func(typ string, data json.RawMessage) {
+		var update struct {
+			Bids     [][2]string `json:"b"`
+			Asks     [][2]string `json:"a"`
+			UpdateID int64       `json:"u"`
+		}
+		if err := json.Unmarshal(data, &update); err != nil {
+			return
+		}
+		// An update id of 1 is a fresh snapshot after a restart on Bybit's side
+		book.apply(typ == "snapshot" || update.UpdateID == 1, update.Bids, update.Asks)
+	})
+}
+
+////////////////////////////////////////////////////////////
+// Perpetuals
+////////////////////////////////////////////////////////////
+
+// bybitNotModified is returned when leverage is already what we ask for,
+// bybitMarginNotModified when the margin mode is
+const (
+	bybitNotModified       = 110043
+	bybitMarginNotModified = 110026
+)
+
+func (e *BybitExchange) SetLeverage(symbol string, leverage float64) error {
+	lev := strconv.FormatFloat(leverage, 'f', -1, 64)
+	params := map[string]interface{}{
+		"category":     e.category,
+		"symbol":       symbol,
+		"buyLeverage":  lev,
+		"sellLeverage": lev,
+	}
+	err := withRetry("Bybit set leverage", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).SetPositionLeverage(context.Background())
+		return decodeBybitResult(res, err, nil)
+	})
+	if exErr, ok := asExchangeError(err); ok && exErr.Code == bybitNotModified {
+		return nil
+	}
+	return err
+}
+
+// SetMarginMode switches symbol alone. Unified accounts only have an
+// account wide margin mode, switching it would move every other position
+// too, so there it is left to the user.
+func (e *BybitExchange) SetMarginMode(symbol string, mode MarginMode, leverage float64) error {
+	if mode == MarginKeep || mode == "" {
+		return nil
+	}
+	tradeMode := 0
+	if mode == MarginIsolated {
+		tradeMode = 1
+	}
+	lev := strconv.FormatFloat(leverage, 'f', -1, 64)
+	params := map[string]interface{}{
+		"category":     e.category,
+		"symbol":       symbol,
+		"tradeMode":    tradeMode,
+		"buyLeverage":  lev,
+		"sellLeverage": lev,
+	}
+	err := withRetry("Bybit set margin mode", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).SwitchPositionMargin(context.Background())
+		return decodeBybitResult(res, err, nil)
+	})
+	if exErr, ok := asExchangeError(err); ok && exErr.Code == bybitMarginNotModified {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w (unified accounts set the margin mode for the whole account, switch it on Bybit and choose keep)", err)
+	}
+	return nil
+}
+
+// Position picks the one-way position, or in hedge mode the one on side
+func (e *BybitExchange) Position(symbol, side string) (*Position, error) {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+	}
+	var data struct {
+		List []struct {
+			PositionIdx   int    `json:"positionIdx"`
+			Side          string `json:"side"`
+			Size          string `json:"size"`
+			AvgPrice      string `json:"avgPrice"`
+			MarkPrice     string `json:"markPrice"`
+			LiqPrice      string `json:"liqPrice"`
+			Leverage      string `json:"leverage"`
+			UnrealisedPnl string `json:"unrealisedPnl"`
+		} `json:"list"`
+	}
+	err := withRetry("Bybit position", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).GetPositionList(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	hedgeIdx := 1 // hedge mode keeps buys on 1 and sells on 2
+	if side == "Sell" {
+		hedgeIdx = 2
+	}
+	for _, p := range data.List {
+		if p.PositionIdx != 0 && p.PositionIdx != hedgeIdx {
+			continue
+		}
+		return &Position{
+			Side:          p.Side,
+			Size:          parseStringToFloat(p.Size),
+			AvgPrice:      parseStringToFloat(p.AvgPrice),
+			MarkPrice:     parseStringToFloat(p.MarkPrice),
+			LiqPrice:      parseStringToFloat(p.LiqPrice),
+			Leverage:      parseStringToFloat(p.Leverage),
+			UnrealizedPNL: parseStringToFloat(p.UnrealisedPnl),
+		}, nil
+	}
+	return &Position{}, nil
+}
+
+// FundingSince reads funding from the transaction log, where it settles as
+// SETTLEMENT entries. The log only serves the last 7 days per query.
+func (e *BybitExchange) FundingSince(symbol string, since time.Time) ([]Funding, error) {
+	if oldest := time.Now().Add(-7*24*time.Hour + time.Minute); since.Before(oldest) {
+		since = oldest
+	}
+
+	var funding []Funding
+	cursor := ""
+	for {
+		params := map[string]interface{}{
+			"accountType": "UNIFIED",
+			"category":    e.category,
+			"type":        "SETTLEMENT",
+			"startTime":   since.UnixMilli() + 1,
+			"limit":       50,
+		}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		// change is the net wallet change of the entry, positive when paid to us
+		var data struct {
+			List []struct {
+				Symbol          string `json:"symbol"`
+				TransactionTime string `json:"transactionTime"`
+				Change          string `json:"change"`
+			} `json:"list"`
+			NextPageCursor string `json:"nextPageCursor"`
+		}
+		err := withRetry("Bybit transaction log", func() error {
+			res, err := e.client.NewUtaBybitServiceWithParams(params).GetTransactionLog(context.Background())
+			return decodeBybitResult(res, err, &data)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range data.List {
+			if t.Symbol != symbol {
+				continue
+			}
+			ms, _ := strconv.ParseInt(t.TransactionTime, 10, 64)
+			funding = append(funding, Funding{Time: time.UnixMilli(ms), Amount: parseStringToFloat(t.Change)})
+		}
+		if data.NextPageCursor == "" || len(data.List) == 0 {
+			break
+		}
+		cursor = data.NextPageCursor
+	}
+
+	// The log lists newest first
+	for i, j := 0, len(funding)-1; i < j; i, j = i+1, j-1 {
+		funding[i], funding[j] = funding[j], funding[i]
+	}
+	return funding, nil
+}
+
+////////////////////////////////////////////////////////////
+// Candles
+////////////////////////////////////////////////////////////
+
+// bybitInterval maps Binance-style intervals to Bybit's: minutes as plain
+// numbers, then D, W and M
+func bybitInterval(interval string) string {
+	switch interval {
+	case "1d", "1D":
+		return "D"
+	case "1w", "1W":
+		return "W"
+	case "1M":
+		return "M"
+	}
+	if d := intervalDuration(interval); d > 0 {
+		return strconv.Itoa(int(d.Minutes()))
+	}
+	return interval
+}
+
+func (e *BybitExchange) Candles(symbol, interval string, limit int) ([]Candle, error) {
+	params := map[string]interface{}{
+		"category": e.category,
+		"symbol":   symbol,
+		"interval": bybitInterval(interval),
+		"limit":    min(limit, 1000),
+	}
+	// Rows are [start, open, high, low, close, volume, turnover]
+	var data struct {
+		List [][]string `json:"list"`
+	}
+	err := withRetry("Bybit klines", func() error {
+		res, err := e.client.NewUtaBybitServiceWithParams(params).GetMarketKline(context.Background())
+		return decodeBybitResult(res, err, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Bybit lists newest first and includes the candle still forming
+	var candles []Candle
+	now := time.Now()
+	for i := len(data.List) - 1; i >= 0; i-- {
+		row := data.List[i]
+		if len(row) < 6 {
+			continue
+		}
+		start, _ := strconv.ParseInt(row[0], 10, 64)
+		closeTime := time.UnixMilli(start).Add(intervalDuration(interval))
+		if closeTime.After(now) {
+			continue
+		}
+		candles = append(candles, Candle{
+			Open:      parseStringToFloat(row[1]),
+			High:      parseStringToFloat(row[2]),
+			Low:       parseStringToFloat(row[3]),
+			Close:     parseStringToFloat(row[4]),
+			Volume:    parseStringToFloat(row[5]),
+			CloseTime: closeTime,
+			IsFinal:   true,
+		})
+	}
+	return candles, nil
+}
+
+func (e *BybitExchange) StreamCandles(symbol, interval string, onCandle func(Candle)) error {
+	return e.stream(fmt.Sprintf("kline.%s.%s", bybitInterval(interval), symbol), func(data json.RawMessage) {
+		var klines []struct {
+			End     int64  `json:"end"`
+			Open    string `json:"open"`
+			High    string `json:"high"`
+			Low     string `json:"low"`
+			Close   string `json:"close"`
+			Volume  string `json:"volume"`
+			Confirm bool   `json:"confirm"`
+		}
+		if err := json.Unmarshal(data, &klines); err != nil {
+			return
+		}
+		for _, k := range klines {
+			if !k.Confirm {
+				continue
+			}
+			onCandle(Candle{
+				Open:      parseStringToFloat(k.Open),
+				High:      parseStringToFloat(k.High),
+				Low:       parseStringToFloat(k.Low),
+				Close:     parseStringToFloat(k.Close),
+				Volume:    parseStringToFloat(k.Volume),
+				CloseTime: time.UnixMilli(k.End + 1),
+				IsFinal:   true,
+			})
+		}
+	})
+}
+
+// floorToStep rounds v down to the exchange step and formats it with the
+// step's number of decimals
+func floorToStep(v, step float64) string {
+	if step <= 0 {
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	return strconv.FormatFloat(math.Floor(v/step+1e-9)*step, 'f', stepDecimals(step), 64)
+}
+
+// ceilToStep rounds v up to the exchange step, used for sell prices so the
+// order never rests below its target
+func ceilToStep(v, step float64) string {
+	if step <= 0 {
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	return strconv.FormatFloat(math.Ceil(v/step-1e-9)*step, 'f', stepDecimals(step), 64)
+}
+
+func stepDecimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
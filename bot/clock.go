@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+)
+
+const (
+	clockSyncEvery = 10 * time.Minute
+	clockMaxDrift  = time.Second
+	recvWindow     = 5000 // ms
+)
+
+// ClockSync tracks how far the local clock is from an exchange's server time
+// so signed requests carry a timestamp the exchange accepts
+type ClockSync struct {
+	mu     sync.RWMutex
+	name   string
+	offset time.Duration
+	fetch  func() (time.Time, error)
+}
+
+// clocks are the ClockSyncs per exchange name, for resyncing after a
+// timestamp error
+var (
+	clocksMu sync.Mutex
+	clocks   = map[string][]*ClockSync{}
+)
+
+func NewClockSync(name string, fetch func() (time.Time, error)) *ClockSync {
+	c := &ClockSync{name: name, fetch: fetch}
+	clocksMu.Lock()
+	clocks[name] = append(clocks[name], c)
+	clocksMu.Unlock()
+	return c
+}
+
+// resyncAfter measures the clocks of the exchange again when err is a
+// timestamp error, a retry on the old offset would only fail the same way
+func resyncAfter(err error) {
+	exErr, ok := asExchangeError(err)
+	if !ok || exErr.Kind != ErrInvalidTimestamp {
+		return
+	}
+	clocksMu.Lock()
+	synced := clocks[exErr.Exchange]
+	clocksMu.Unlock()
+	for _, c := range synced {
+		if err := c.Sync(); err != nil {
+			log.Printf("%s clock sync error: %v", c.name, err)
+		}
+	}
+}
+
+// Now is the local time corrected by the last measured offset
+func (c *ClockSync) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+func (c *ClockSync) Offset() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset
+}
+
+// Sync measures the offset, taking the midpoint of the round trip as the
+// moment the server read its clock
+func (c *ClockSync) Sync() error {
+	start := time.Now()
+	server, err := c.fetch()
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+
+	offset := server.Sub(start.Add(end.Sub(start) / 2))
+
+	c.mu.Lock()
+	c.offset = offset
+	c.mu.Unlock()
+
+	if offset > clockMaxDrift || offset < -clockMaxDrift {
+		log.Printf("⚠️ Local clock is %v off %s server time, check NTP on this host", offset, c.name)
+	}
+	return nil
+}
+
+// Run keeps the offset fresh until the process exits
+func (c *ClockSync) Run() {
+	ticker := time.NewTicker(clockSyncEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := c.Sync(); err != nil {
+			log.Printf("%s clock sync error: %v", c.name, err)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Binance
+////////////////////////////////////////////////////////////
+
+// binanceClocks holds one clock per environment, testnet and mainnet bots in
+// one process each sign with their own hosts' time
+var (
+	binanceClocksMu sync.Mutex
+	binanceClocks   = map[string]*ClockSync{}
+)
+
+// binanceClock is env's Binance clock, synced on first use and kept in sync
+// from then on
+func binanceClock(env Environment) *ClockSync {
+	binanceClocksMu.Lock()
+	defer binanceClocksMu.Unlock()
+
+	if clock, ok := binanceClocks[env.Name]; ok {
+		return clock
+	}
+	clock := NewClockSync("binance", func() (time.Time, error) {
+		return fetchBinanceServerTime(env)
+	})
+	if err := clock.Sync(); err != nil {
+		log.Println("Binance clock sync error:", err)
+	}
+	go clock.Run()
+	binanceClocks[env.Name] = clock
+	return clock
+}
+
+func fetchBinanceServerTime(env Environment) (time.Time, error) {
+	req, err := http.NewRequest("GET", env.BinanceFuturesREST+"/fapi/v1/time", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := binanceDo(req, PriorityMarketData, 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if err := binanceError(resp.StatusCode, body); err != nil {
+		return time.Time{}, err
+	}
+
+	var data struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(data.ServerTime), nil
+}
+
+////////////////////////////////////////////////////////////
+// Bybit
+////////////////////////////////////////////////////////////
+
+// NewBybitClock measures against Bybit's /v5/market/time
+func NewBybitClock(client *bybit.Client) *ClockSync {
+	return NewClockSync("bybit", func() (time.Time, error) {
+		res, err := client.NewUtaBybitServiceNoParams().GetServerTime(context.Background())
+
+		var data struct {
+			TimeNano string `json:"timeNano"`
+		}
+		if err := decodeBybitResult(res, err, &data); err != nil {
+			return time.Time{}, err
+		}
+		nanos, err := strconv.ParseInt(data.TimeNano, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, nanos), nil
+	})
+}
+
+// UseBybitClock re-signs every private Bybit request with the synced clock
+// and our recv window. The SDK signs with the raw local time and has no hook
+// for an offset, so the signature is redone at the transport.
+func UseBybitClock(client *bybit.Client, clock *ClockSync) {
+	base := client.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.HTTPClient.Transport = &bybitSigner{
+		base:   base,
+		apiKey: client.APIKey,
+		secret: client.APISecret,
+		clock:  clock,
+	}
+}
+
+type bybitSigner struct {
+	base   http.RoundTripper
+	apiKey string
+	secret string
+	clock  *ClockSync
+}
+
+func (s *bybitSigner) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("X-BAPI-SIGN") == "" {
+		return s.base.RoundTrip(req)
+	}
+
+	signed := req.Clone(req.Context())
+	payload := req.URL.RawQuery
+	if req.Body != nil && req.Method == http.MethodPost {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+		payload = string(body)
+	}
+
+	timestamp := strconv.FormatInt(s.clock.Now().UnixMilli(), 10)
+	window := strconv.Itoa(recvWindow)
+
+	signed.Header.Set("X-BAPI-TIMESTAMP", timestamp)
+	signed.Header.Set("X-BAPI-RECV-WINDOW", window)
+	signed.Header.Set("X-BAPI-SIGN", sign(timestamp+s.apiKey+window+payload, s.secret))
+
+	return s.base.RoundTrip(signed)
+}
@@ -0,0 +1,30 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResyncAfterTimestampError(t *testing.T) {
+	server := time.Now().Add(3 * time.Second)
+	calls := 0
+	clock := NewClockSync("clocktest", func() (time.Time, error) {
+		calls++
+		return server, nil
+	})
+
+	resyncAfter(&ExchangeError{Exchange: "clocktest", Kind: ErrRateLimit})
+	resyncAfter(errors.New("clocktest timeout"))
+	if calls != 0 {
+		t.Fatalf("synced on a non-timestamp error")
+	}
+
+	resyncAfter(&ExchangeError{Exchange: "clocktest", Kind: ErrInvalidTimestamp})
+	if calls != 1 {
+		t.Fatalf("clock synced %d times, want 1", calls)
+	}
+	if offset := clock.Offset(); offset < 2*time.Second {
+		t.Errorf("offset %v after resync", offset)
+	}
+}
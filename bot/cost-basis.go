@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////
+// Cost Basis
+////////////////////////////////////////////////////////////
+
+// CostBasis decides which buys a sell is matched against, and so the
+// realized PNL, the records left and the ledger. Lot exits always match the
+// lots they sell.
+type CostBasis string
+
+const (
+	CostFIFO    CostBasis = "fifo"    // oldest buys first
+	CostLIFO    CostBasis = "lifo"    // newest buys first
+	CostHIFO    CostBasis = "hifo"    // dearest buys first, the smallest taxable gain
+	CostAverage CostBasis = "average" // every buy in proportion, at the average cost
+)
+
+func ParseCostBasis(s string) (CostBasis, error) {
+	switch c := CostBasis(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return CostFIFO, nil
+	case CostFIFO, CostLIFO, CostHIFO, CostAverage:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown cost basis %q, use fifo, lifo, hifo or average", s)
+}
+
+// sellOrder is the order records are consumed in by the lot-matching methods
+func (b *DCABot) sellOrder() []int {
+	order := make([]int, len(b.Records))
+	for i := range order {
+		order[i] = i
+	}
+	switch b.CostBasis {
+	case CostLIFO:
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	case CostHIFO:
+		// The dearest entry of a short is the lowest one it sold at
+		sort.SliceStable(order, func(i, j int) bool {
+			return b.dir()*(b.Records[order[i]].Price-b.Records[order[j]].Price) > 0
+		})
+	}
+	return order
+}
+
+// bookAverage takes a sell from every record in proportion, so what is left
+// keeps the same average cost, and realizes the PNL against that average
+func (b *DCABot) bookAverage(price, sellQty float64) float64 {
+	holdings := b.totalHoldings()
+	if holdings == 0 {
+		return 0
+	}
+	avg := b.avgBuyPrice()
+	used := math.Min(sellQty, holdings)
+	share := used / holdings
+	for i := range b.Records {
+		r := &b.Records[i]
+		if share >= 1 {
+			r.AmountBought = 0
+		} else {
+			r.AmountBought -= r.AmountBought * share
+		}
+	}
+
+	realizedPNL := b.dir() * (price - avg) * used
+	b.settleSell(price, sellQty, avg*used, realizedPNL, string(CostAverage))
+	return realizedPNL
+}
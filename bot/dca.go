@@ -1,19 +1,22 @@
 package bot
 
 import (
-	"context"
 	"dca-bot/config"
 	"dca-bot/constant"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
+	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	bybit "github.com/bybit-exchange/bybit.go.api"
-	"github.com/gorilla/websocket"
 )
 
+// buyRetryAfter spaces out retries of a refused buy, so a missing balance
+// alerts once a minute rather than on every tick
+const buyRetryAfter = time.Minute
+
 type DCABot struct {
 	Symbol         string
 	DropPercent    float64
@@ -27,8 +30,248 @@ type DCABot struct {
 	FallbackHours  time.Duration
 	LatestDayPrice float64
 	RealizedPNL    float64
-	BybitClient    *bybit.Client
-	Category       string
+	Exchange       SpotExchange
+
+	// OnPrice, the Telegram commands and the daily report take turns on it
+	mu sync.Mutex
+
+	// Exchange-native take profit
+	NativeTakeProfit bool
+	TPOrderID        string
+	TPOrderPrice     float64
+	TPOrderQty       float64
+	TPFilledQty      float64
+	lastTPCheck      time.Time
+	tpNotified       atomic.Bool // set by the order stream on a fill
+	instrument       *Instrument
+
+	// Order tracking
+	BotID      string
+	DealNumber int
+	DealStep   int
+	Intents    map[string]*OrderIntent
+	Store      StateStore
+
+	// How the fills reconcileIntents finds are booked, by link id, when not
+	// as a plain buy or sell
+	fillBooks     map[string]func(price, qty, value float64)
+	lastReconcile time.Time
+
+	// Exchange balances, checked before every buy
+	Account AccountService
+
+	// Set when an order fails in a way retrying can't fix
+	Paused      bool
+	PauseReason string
+	buyRetryAt  time.Time // a refused buy waits until then, not the next tick
+	exitRetryAt time.Time // the same for an exit the book couldn't fill
+
+	// Safety orders
+	BaseOrderUSDT   float64
+	SafetyOrderUSDT float64
+	VolumeScale     float64
+	StepScale       float64
+	MaxSafetyOrders int
+	BasePrice       float64
+	SafetyCount     int
+
+	// Trailing take profit, off when TrailingDeviation is 0
+	TrailingDeviation float64
+	TrailingActive    bool
+	TrailingHigh      float64
+	lastTrailingSave  time.Time
+
+	// Take profit ladder, a single full sell at SellPercent when empty
+	TPLadder []TPLevel
+	TPLevel  int
+
+	// Downside protections, each off when 0
+	StopLossPercent  float64
+	StopLossAction   ProtectAction
+	MaxDrawdownUSDT  float64
+	DrawdownAction   ProtectAction
+	FloorPrice       float64
+	FloorAction      ProtectAction
+	ProtectHit       map[string]bool
+	protectRetryAt   time.Time
+	BuysPaused       bool
+	BuysPausedReason string
+
+	// Indicator filters on drop buys, Candles is the exchange's own feed
+	EntryFilter EntryFilter
+	Candles     CandleFeed
+	closes      []float64
+	candleMu    sync.Mutex
+	lastSkip    string
+	lastSkipLog time.Time
+
+	// Scheduled buys replace the drop triggers when Schedule is set
+	Schedule         Schedule
+	ScheduleUSDT     float64
+	BoostPercent     float64
+	BoostMultiplier  float64
+	CatchUp          CatchUpPolicy
+	NextScheduledBuy time.Time
+	scheduleHeld     time.Time // the slot a refused buy holds, alerted once
+
+	// Value averaging, off when ValueStepUSDT is 0
+	ValueStepUSDT float64
+	MaxBuyUSDT    float64
+	MaxSellUSDT   float64
+	ValuePeriods  int
+
+	// Deal lifecycle, Deal is nil between deals
+	Deal           *Deal
+	Deals          []Deal
+	LastDealClosed time.Time
+	DealCooldown   time.Duration
+	StopAfterDeal  bool
+
+	// Profit reinvestment, the vault is never spent
+	Reinvest     ReinvestPolicy
+	VaultPercent float64
+	Vault        float64
+	Reinvested   float64
+	InitialUSDT  float64
+
+	// Short DCA on perpetuals, Futures is set only for short bots
+	Short             bool
+	Leverage          float64
+	MarginMode        MarginMode
+	LiqAlertPercent   float64
+	Futures           FuturesExchange
+	LiqPrice          float64
+	FundingPNL        float64
+	LastFundingTime   time.Time
+	lastPositionCheck time.Time
+	lastFundingCheck  time.Time
+	liqAlerted        bool
+
+	// ATR-adaptive steps, the fixed percentages apply until an ATR is known
+	ATRStep      ATRStep
+	ATR          float64
+	atrPercent   float64
+	lastATRCheck time.Time
+
+	// Trailing buy, off when TrailingBuyRebound is 0. TrailingBuyKind names
+	// the armed order and is empty while nothing waits for a bounce.
+	TrailingBuyRebound float64
+	TrailingBuyMaxWait time.Duration
+	TrailingBuyKind    string
+	TrailingBuyLow     float64
+	TrailingBuySince   time.Time
+
+	// Lot-based exits sell each record at its own target
+	ExitMode ExitMode
+	LotSells int
+	LotPNL   float64
+
+	// How sells are matched to buys for PNL, records and the ledger
+	CostBasis CostBasis
+
+	// Market regime from daily and weekly candles, empty until first read
+	RegimeFilter    RegimeFilter
+	Regime          Regime
+	regimeStats     regimeStats
+	lastRegimeCheck time.Time
+
+	// Order book guard on market orders, Book is nil without a feed
+	BookGuard     BookGuard
+	Book          *OrderBook
+	bookHeldSince time.Time // a delayed order waits on the book since then
+	bookHeldSide  string
+	split         *splitOrder // pieces still to send, one every few ticks
+}
+
+// DCAConfig is everything a DCA run is started with
+type DCAConfig struct {
+	Environment      string // its name, part of the state id
+	Symbol           string
+	TotalUSDT        float64
+	DropPercent      float64
+	SellPercent      float64
+	FallbackBuyHours int
+	NativeTakeProfit bool
+
+	// Safety orders, off when MaxSafetyOrders is 0
+	BaseOrderUSDT   float64
+	SafetyOrderUSDT float64
+	VolumeScale     float64
+	StepScale       float64
+	MaxSafetyOrders int
+
+	// Trailing take profit, off when 0
+	TrailingDeviation float64
+
+	// Take profit ladder, replaces the full sell at SellPercent when set
+	TPLadder []TPLevel
+
+	// Downside protections, each off when 0
+	StopLossPercent float64
+	StopLossAction  ProtectAction
+	MaxDrawdownUSDT float64
+	DrawdownAction  ProtectAction
+	FloorPrice      float64
+	FloorAction     ProtectAction
+
+	// Indicator filters on drop buys, off when none is set
+	EntryFilter EntryFilter
+
+	// Scheduled buys, ScheduleUSDT every slot of Schedule instead of buying
+	// on drops. BoostMultiplier applies below BoostPercent under the 30-day
+	// average.
+	Schedule        Schedule
+	ScheduleUSDT    float64
+	BoostPercent    float64
+	BoostMultiplier float64
+	CatchUp         CatchUpPolicy
+
+	// Value averaging on the same Schedule: the target value grows by
+	// ValueStepUSDT every slot and each slot trades the gap, capped by
+	// MaxBuyUSDT and MaxSellUSDT. Sells are off when MaxSellUSDT is 0.
+	ValueStepUSDT float64
+	MaxBuyUSDT    float64
+	MaxSellUSDT   float64
+
+	// Deals restart right away after a close unless a cooldown is set or
+	// StopAfterDeal pauses buys until /resume
+	DealCooldownMinutes int
+	StopAfterDeal       bool
+
+	// What realized profit does, fixed when empty. VaultPercent is the share
+	// of each profit the vault policy locks away.
+	Reinvest     ReinvestPolicy
+	VaultPercent float64
+
+	// Short DCA on a perpetual: sells to open, adds on rises of DropPercent
+	// and buys back SellPercent under the average. Entry sizes are margin,
+	// the position is Leverage times that. Alerts when the liquidation
+	// price comes within LiqAlertPercent of the mark price.
+	Short           bool
+	Leverage        float64
+	MarginMode      MarginMode
+	LiqAlertPercent float64
+
+	// ATR-adaptive buy spacing and sell target, off when empty
+	ATRStep ATRStep
+
+	// Triggered buys wait for a TrailingBuyRebound % bounce off the low, at
+	// most TrailingBuyMaxWaitMinutes (0 = no limit). Off when 0.
+	TrailingBuyRebound        float64
+	TrailingBuyMaxWaitMinutes int
+
+	// Take profit on the average (default) or lot by lot
+	ExitMode ExitMode
+
+	// Lot matching for realized PNL, FIFO when empty
+	CostBasis CostBasis
+
+	// Bull, range and bear adjustments to buying, off unless enabled
+	RegimeFilter RegimeFilter
+
+	// Spread and slippage limits for market orders, checked against the
+	// live order book
+	BookGuard BookGuard
 }
 
 type DCARecord struct {
@@ -40,139 +283,479 @@ type DCARecord struct {
 	TotalHoldings float64
 }
 
-func NewDCABot(client *bybit.Client, symbol string, totalUSDT, dropPercent, sellPercent float64, fallbackBuyHours int) *DCABot {
+func NewDCABot(exchange SpotExchange, symbol string, totalUSDT, dropPercent, sellPercent float64, fallbackBuyHours int) *DCABot {
 	return &DCABot{
 		Symbol:        strings.ToUpper(symbol),
 		DropPercent:   dropPercent,
 		SellPercent:   sellPercent,
 		TotalUSDT:     totalUSDT,
 		OneBuyUSDT:    1,
+		VolumeScale:   1,
+		StepScale:     1,
 		Records:       []DCARecord{},
 		FallbackHours: time.Duration(fallbackBuyHours) * time.Hour,
-		BybitClient:   client,
-		Category:      "spot",
+		Exchange:      exchange,
+		BotID:         dcaBotID(exchange.Name(), "", symbol, dropPercent),
+		Intents:       map[string]*OrderIntent{},
 	}
 }
 
 func (b *DCABot) OnPrice(price float64, token string) {
 	b.LatestDayPrice = price
 
-	if !b.Started {
-		fmt.Printf("\nDCA START — FIRST BUY at %.4f\n", price)
-		b.executeBuy(price, token)
-		b.LastBuyPrice = price
-		b.LastBuyTime = time.Now()
-		b.Started = true
+	if b.Short {
+		b.checkPosition(price, token)
+	}
+	b.checkDealClosed(price, token)
+	b.refreshATR(price, token)
+	b.refreshRegime(token)
+
+	if b.checkProtections(price, token) {
+		return
+	}
+	if b.settleOrders(token) {
 		return
 	}
 
-	drop := ((b.LastBuyPrice - price) / b.LastBuyPrice) * 100
-	if drop >= b.DropPercent {
-		fmt.Printf("PRICE DROP %.2f%% → BUY triggered\n", drop)
-		b.executeBuy(price, token)
-		b.LastBuyPrice = price
-		b.LastBuyTime = time.Now()
+	if b.scheduleEnabled() {
+		b.checkSchedule(price, token)
+		if b.valueAveraging() {
+			return // the value path does its own selling
+		}
+	} else if b.checkDropBuys(price, token) {
 		return
 	}
 
-	rise := ((price - b.LastBuyPrice) / b.LastBuyPrice) * 100
-	if time.Since(b.LastBuyTime) >= b.FallbackHours && rise >= b.DropPercent {
+	b.checkExits(price, token)
+}
+
+// checkDropBuys runs the price driven buys and reports whether one went out
+func (b *DCABot) checkDropBuys(price float64, token string) bool {
+	if time.Now().Before(b.buyRetryAt) {
+		return false
+	}
+	if b.TrailingBuyKind != "" {
+		return b.checkTrailingBuy(price, token)
+	}
+
+	if !b.Started {
+		if reason := b.dropBuyBlocked(price); reason != "" {
+			b.logSkippedBuy("first buy", price, reason)
+			return false
+		}
+		fmt.Printf("\nDCA START — FIRST BUY at %.4f\n", price)
+		return b.triggerBuy("first buy", price, token)
+	}
+
+	if b.safetyEnabled() && b.totalHoldings() > 0 {
+		if next := b.nextSafetyPrice(); next > 0 && b.adverseMove(next, price) >= 0 {
+			if reason := b.dropBuyBlocked(price); reason != "" {
+				b.logSkippedBuy(fmt.Sprintf("safety order #%d", b.SafetyCount+1), price, reason)
+			} else {
+				fmt.Printf("SAFETY ORDER #%d → Price %.4f ≤ %.4f\n", b.SafetyCount+1, price, next)
+				return b.triggerBuy(fmt.Sprintf("safety order #%d", b.SafetyCount+1), price, token)
+			}
+		}
+	} else if drop := b.adverseMove(b.LastBuyPrice, price); drop >= b.dropStep() {
+		if reason := b.dropBuyBlocked(price); reason != "" {
+			b.logSkippedBuy(fmt.Sprintf("%.2f%% drop buy", drop), price, reason)
+		} else {
+			fmt.Printf("PRICE DROP %.2f%% → BUY triggered\n", drop)
+			return b.triggerBuy(fmt.Sprintf("%.2f%% drop buy", drop), price, token)
+		}
+	}
+
+	// A fallback buy takes the next safety order, so it stops with the ladder
+	ladderFull := b.safetyEnabled() && b.totalHoldings() > 0 && b.SafetyCount >= b.MaxSafetyOrders
+	rise := -b.adverseMove(b.LastBuyPrice, price)
+	if !ladderFull && time.Since(b.LastBuyTime) >= b.FallbackHours && rise >= b.dropStep() {
 		fmt.Printf("FALLBACK BUY → Rise %.2f%% after %v\n", rise, b.FallbackHours)
-		b.executeBuy(price, token)
-		b.LastBuyPrice = price
-		b.LastBuyTime = time.Now()
+		return b.buyNow(price, token) // already buying into strength, no need to trail
+	}
+	return false
+}
+
+// dropBuyBlocked is why a triggered drop buy can't go out, checked before
+// the trigger arms anything so a long pause logs once a minute, not per tick
+func (b *DCABot) dropBuyBlocked(price float64) string {
+	if reason := b.buyBlocked(price); reason != "" {
+		return reason
+	}
+	return b.entryBlocked(price)
+}
+
+// checkExits takes profit on the holdings, on the exchange or here
+func (b *DCABot) checkExits(price float64, token string) {
+	if b.TPOrderID != "" {
+		// The exchange owns the exit, we only need to notice fills. Polling
+		// only at the target would miss fills from a wick the stream skipped.
+		notified := b.tpNotified.Swap(false)
+		since := time.Since(b.lastTPCheck)
+		if notified || (price >= b.TPOrderPrice && since >= tpCheckAtTarget) || since >= tpCheckEvery {
+			b.checkTakeProfitFill(token)
+			if b.TPOrderID == "" {
+				b.syncTakeProfit(token) // next ladder level
+			}
+		}
+		return
+	}
+	if time.Now().Before(b.exitRetryAt) {
+		return
+	}
+
+	if b.ExitMode == ExitLot {
+		b.checkLotExits(price, token)
 		return
 	}
 
 	avgPrice := b.avgBuyPrice()
-	if avgPrice > 0 {
-		targetPrice := avgPrice * (1 + b.SellPercent/100)
-		if price >= targetPrice {
-			fmt.Printf("SELL triggered → Price %.4f ≥ Target %.4f\n", price, targetPrice)
-			b.executeSell(price, token)
-		}
+	if avgPrice == 0 {
+		b.resetTrailing()
+		return
+	}
+
+	targetPrice, fraction := b.nextTakeProfit()
+	if targetPrice == 0 {
+		return // ladder done for this deal
+	}
+	if b.trailingEnabled() {
+		b.checkTrailingExit(price, targetPrice, fraction, token)
+		return
+	}
+	if b.dir()*(price-targetPrice) >= 0 {
+		fmt.Printf("SELL triggered → Price %.4f ≥ Target %.4f\n", price, targetPrice)
+		b.takeProfit(price, fraction, token)
 	}
 }
 
-func (b *DCABot) executeBuy(price float64, token string) {
-	if b.TotalUSDT < b.OneBuyUSDT {
+// executeBuy places a buy and reports whether it went out. A refused buy
+// holds the buy triggers off for buyRetryAfter.
+func (b *DCABot) executeBuy(price, usdt float64, token string) bool {
+	if len(b.Intents) > 0 {
+		b.reconcileIntents(token)
+	}
+
+	if reason := b.buyBlocked(price); reason != "" {
+		log.Printf("%s buy at %.4f skipped: %s", b.Symbol, price, reason)
+		return b.retryBuyLater()
+	}
+
+	if b.TotalUSDT < usdt {
 		sendTelegramMessage(token, "❗ No more USDT left for DCA.")
-		return
+		return b.retryBuyLater()
+	}
+
+	if !b.hasFunds(usdt, token) {
+		return b.retryBuyLater()
+	}
+
+	if err := b.loadInstrument(); err != nil {
+		log.Printf("%s instrument error: %v", b.Exchange.Name(), err)
+	} else if usdt*b.leverage() < b.instrument.MinOrderAmt {
+		b.pauseBuys(fmt.Sprintf("a %.2f USDT buy below the %s minimum of %.2f USDT", usdt*b.leverage(), b.Exchange.Name(), b.instrument.MinOrderAmt), token)
+		return b.retryBuyLater()
 	}
 
-	params := map[string]interface{}{
-		"category":  b.Category,
-		"symbol":    b.Symbol,
-		"side":      "Buy",
-		"orderType": "Market",
-		"qty":       fmt.Sprintf("%.2f", b.OneBuyUSDT), // For Spot Market Buy, qty is USDT
+	newDeal := b.totalHoldings() == 0
+	if newDeal {
+		// A deal whose base order never filled is retried under its number
+		if b.Deal == nil || b.Deal.Buys > 0 {
+			b.DealNumber++
+			b.DealStep = 0
+			b.openDeal()
+		}
+		b.TPLevel = 0
+		b.rearmProtections()
 	}
 
-	_, err := b.BybitClient.NewUtaBybitServiceWithParams(params).PlaceOrder(context.Background())
+	notional := usdt * b.leverage()
+	req := OrderRequest{
+		Symbol:   b.Symbol,
+		Side:     b.entrySide(),
+		Type:     "Market",
+		QuoteQty: fmt.Sprintf("%.2f", usdt), // spot market buys are sized in USDT
+	}
+	intentQty := req.QuoteQty
+	if b.Short {
+		// Perpetuals are sized in coins, margin times leverage
+		req.QuoteQty = ""
+		req.Qty = fmt.Sprintf("%.6f", notional/price)
+		if b.instrument != nil {
+			req.Qty = floorToStep(notional/price, b.instrument.QtyStep)
+		}
+		intentQty = req.Qty
+	}
+	intent := &OrderIntent{LinkID: b.nextOrderLinkID("B"), Side: req.Side, Qty: intentQty, Price: price}
+
+	bookFill := func(price, qty, value float64) {
+		b.bookBuy(price, qty, value/b.leverage())
+	}
+	order, err := b.placeMarket(intent, req, bookFill)
+	if errors.Is(err, errBookHeld) {
+		return false // tried again on the next tick
+	}
 	if err != nil {
-		log.Printf("Bybit Buy API Error: %v", err)
+		log.Printf("%s %s API Error: %v", b.Exchange.Name(), req.Side, err)
+		b.handleOrderError(req.Side, err, token)
+		if errors.Is(err, errFillPending) {
+			b.bookLater(intent.LinkID, bookFill, nil)
+			return true // it went out, reconcileIntents books the fill
+		}
+		return b.retryBuyLater()
+	}
+
+	// Book the real fill when the exchange reports it, otherwise the tick
+	qty, spent := notional/price, usdt
+	if order.FilledQty > 0 {
+		if value := order.Value(); value > 0 {
+			qty, spent = order.FilledQty, value/b.leverage()
+			price = value / qty
+		}
+	}
+
+	record := b.bookBuy(price, qty, spent)
+	if !newDeal && b.Deal != nil {
+		b.Deal.SafetyOrders++
+	}
+	b.finishIntent(intent.LinkID)
+	b.refreshAccount()
+
+	label := fmt.Sprintf("BUY #%d", record.BuyNumber)
+	if b.Short {
+		label = fmt.Sprintf("SHORT #%d", record.BuyNumber)
+	}
+	if b.safetyEnabled() {
+		if newDeal {
+			b.BasePrice = price
+			b.SafetyCount = 0
+			label = "BASE ORDER"
+		} else {
+			b.SafetyCount++
+			label = fmt.Sprintf("SAFETY ORDER %d/%d", b.SafetyCount, b.MaxSafetyOrders)
+		}
+		b.saveState()
+	}
+
+	message := fmt.Sprintf("📉 %s %s\nSymbol: %s\nPrice: %.4f\nSpent: %.2f USDT\nAvg: %.4f",
+		strings.ToUpper(b.Exchange.Name()), label, b.Symbol, price, spent, b.avgBuyPrice())
+	if next := b.nextSafetyPrice(); b.safetyEnabled() && next > 0 {
+		message += fmt.Sprintf("\nNext SO: %.4f (%.2f USDT)", next, b.nextOrderUSDT())
+	}
+	if thresholds := b.atrStatus(); thresholds != "" {
+		message += "\n" + thresholds
+	}
+	sendTelegramMessage(token, message)
+
+	b.syncTakeProfit(token)
+	return true
+}
+
+// retryBuyLater holds the buy triggers off for buyRetryAfter and reports the
+// buy as not placed
+func (b *DCABot) retryBuyLater() bool {
+	b.buyRetryAt = time.Now().Add(buyRetryAfter)
+	return false
+}
+
+// hasFunds checks the exchange balance rather than trusting TotalUSDT alone,
+// which knows nothing about transfers or other bots on the account
+func (b *DCABot) hasFunds(usdt float64, token string) bool {
+	if b.Account == nil {
+		return true
+	}
+	if time.Since(b.Account.Updated()) > 30*time.Second {
+		if err := b.Account.Refresh(); err != nil {
+			log.Printf("Account refresh error: %v", err)
+		}
+	}
+
+	// The vault sits on the same account but is not ours to spend
+	free := b.Account.Balance("USDT").Free - b.Vault
+	if free < usdt {
+		message := fmt.Sprintf("❗ Not enough USDT on the exchange for %s DCA.\nFree: %.2f USDT\nVault: %.2f USDT\nNeeded: %.2f USDT", b.Symbol, free, b.Vault, usdt)
+		sendTelegramMessage(token, message)
+		return false
+	}
+	return true
+}
+
+func (b *DCABot) refreshAccount() {
+	if b.Account == nil {
 		return
 	}
+	go func() {
+		if err := b.Account.Refresh(); err != nil {
+			log.Printf("Account refresh error: %v", err)
+		}
+	}()
+}
 
-	qty := b.OneBuyUSDT / price
-	b.TotalUSDT -= b.OneBuyUSDT
+// bookBuy records a filled buy against the DCA budget
+func (b *DCABot) bookBuy(price, qty, usdt float64) DCARecord {
+	b.TotalUSDT -= usdt
 
 	record := DCARecord{
 		BuyNumber:     len(b.Records) + 1,
 		Price:         price,
-		USDTSpent:     b.OneBuyUSDT,
+		USDTSpent:     usdt,
 		AmountBought:  qty,
 		RemainingUSDT: b.TotalUSDT,
 		TotalHoldings: b.totalHoldings() + qty,
 	}
 	b.Records = append(b.Records, record)
+	if b.Deal != nil {
+		b.Deal.Buys++
+		b.Deal.CapitalUSDT += usdt
+	}
+	b.saveState()
 
-	message := fmt.Sprintf("📉 BYBIT BUY #%d\nSymbol: %s\nPrice: %.4f\nSpent: %.2f USDT\nAvg: %.4f",
-		record.BuyNumber, b.Symbol, price, b.OneBuyUSDT, b.avgBuyPrice())
-	sendTelegramMessage(token, message)
+	b.recordLedger(b.entrySide(), price, qty, price*qty, 0, "")
+	return record
 }
 
-func (b *DCABot) executeSell(price float64, token string) {
+// executeSell sells fraction of the holdings at market and reports whether
+// the sell went out. done runs once all of it is booked, which is on a later
+// tick when the book guard split it or its fill was still unknown. Unguarded
+// sells skip the book guard. Callers run it only with no other order in
+// flight, see settleOrders.
+func (b *DCABot) executeSell(price, fraction float64, guarded bool, token string, done func()) bool {
 	if len(b.Records) == 0 {
-		return
+		return false
 	}
 
+	if len(b.Intents) > 0 {
+		b.reconcileIntents(token)
+	}
+
+	// Spot limit orders lock the coins, free them before selling at market
+	b.cancelTakeProfit(token)
+
 	totalHoldings := b.totalHoldings()
-	sellQty := totalHoldings * 0.5 // Example: Sell 50%
-	sellUSDT := sellQty * price
+	if totalHoldings == 0 {
+		return false
+	}
+
+	var soldQty, soldValue, realizedPNL float64
+	book := func(price, qty float64) float64 {
+		pnl := b.bookSell(price, qty)
+		soldQty += qty
+		soldValue += price * qty
+		realizedPNL += pnl
+		return pnl
+	}
+	sold := func() {
+		action := "SELL"
+		if b.Short {
+			action = "BUY BACK"
+		}
+		message := fmt.Sprintf("🔴 %s %s\nPrice: %.4f\nQty: %.6f\nRealized: %.2f", strings.ToUpper(b.Exchange.Name()), action, soldValue/soldQty, soldQty, realizedPNL)
+		sendTelegramMessage(token, message)
+
+		if done != nil {
+			done()
+		}
+		b.syncTakeProfit(token)
+	}
 
-	params := map[string]interface{}{
-		"category":  b.Category,
-		"symbol":    b.Symbol,
-		"side":      "Sell",
-		"orderType": "Market",
-		"qty":       fmt.Sprintf("%.6f", sellQty),
+	if !b.sellAtMarket(price, totalHoldings*fraction, guarded, book, sold, token) {
+		b.syncTakeProfit(token)
+		return false
+	}
+	return true
+}
+
+// sellAtMarket closes sellQty, floored to the lot step, hands every fill to
+// book and runs done once the last one is booked. It reports whether the
+// order went out.
+func (b *DCABot) sellAtMarket(price, sellQty float64, guarded bool, book func(price, qty float64) float64, done func(), token string) bool {
+	qty := fmt.Sprintf("%.6f", sellQty)
+	if err := b.loadInstrument(); err == nil {
+		qty = floorToStep(sellQty, b.instrument.QtyStep)
+	}
+	sellQty = parseStringToFloat(qty)
+	if sellQty == 0 {
+		return false
 	}
 
-	_, err := b.BybitClient.NewUtaBybitServiceWithParams(params).PlaceOrder(context.Background())
+	req := OrderRequest{Symbol: b.Symbol, Side: b.exitSide(), Type: "Market", Qty: qty, ReduceOnly: b.Short}
+	intent := &OrderIntent{LinkID: b.nextOrderLinkID("S"), Side: req.Side, Qty: qty, Price: price}
+
+	bookFill := func(price, qty, _ float64) { book(price, qty) }
+	var filled *Order
+	var err error
+	if guarded {
+		filled, err = b.placeMarket(intent, req, bookFill)
+	} else {
+		filled, err = b.submitOrder(intent, req)
+	}
+	if errors.Is(err, errBookHeld) {
+		return false // the exit fires again on the next tick
+	}
 	if err != nil {
-		log.Printf("Bybit Sell API Error: %v", err)
-		return
+		log.Printf("%s %s API Error: %v", b.Exchange.Name(), req.Side, err)
+		b.handleOrderError(req.Side, err, token)
+		if errors.Is(err, errFillPending) {
+			b.bookLater(intent.LinkID, bookFill, done)
+			return true
+		}
+		if errors.Is(err, errNoBookFill) {
+			b.exitRetryAt = time.Now().Add(buyRetryAfter)
+		}
+		return false
 	}
 
-	// FIFO Logic
+	if filled.FilledQty > 0 && filled.AvgPrice > 0 {
+		sellQty, price = filled.FilledQty, filled.AvgPrice
+	}
+
+	book(price, sellQty)
+	b.finishIntent(intent.LinkID)
+	b.refreshAccount()
+	b.whenBooked(done)
+	return true
+}
+
+// bookSell consumes records for a filled sell by the bot's cost-basis method
+// and returns the realized PNL
+func (b *DCABot) bookSell(price, sellQty float64) float64 {
+	if b.CostBasis == CostAverage {
+		return b.bookAverage(price, sellQty)
+	}
+	return b.bookRecords(price, sellQty, b.sellOrder(), string(b.CostBasis))
+}
+
+// bookRecords consumes the records at the given indexes, in that order, for
+// a filled sell and returns the realized PNL
+func (b *DCABot) bookRecords(price, sellQty float64, order []int, method string) float64 {
 	remaining := sellQty
 	realizedPNL := 0.0
-	for i := 0; i < len(b.Records) && remaining > 0; i++ {
-		r := &b.Records[i]
-		if r.AmountBought <= remaining {
-			realizedPNL += (price - r.Price) * r.AmountBought
-			remaining -= r.AmountBought
-			r.AmountBought = 0
-		} else {
-			realizedPNL += (price - r.Price) * remaining
-			r.AmountBought -= remaining
-			remaining = 0
+	costBasis := 0.0
+	for _, i := range order {
+		if remaining <= 0 {
+			break
 		}
+		r := &b.Records[i]
+		used := math.Min(r.AmountBought, remaining)
+		realizedPNL += b.dir() * (price - r.Price) * used
+		costBasis += r.Price * used
+		r.AmountBought -= used
+		remaining -= used
 	}
+	b.settleSell(price, sellQty, costBasis, realizedPNL, method)
+	return realizedPNL
+}
 
-	b.TotalUSDT += sellUSDT
+// settleSell books what a sell released into the budget, the PNL and the
+// ledger, and drops emptied records
+func (b *DCABot) settleSell(price, sellQty, costBasis, realizedPNL float64, method string) {
+	// The margin comes back with the PNL, on spot that is simply the proceeds
+	b.TotalUSDT += costBasis/b.leverage() + realizedPNL
 	b.RealizedPNL += realizedPNL
+	b.bookProfit(realizedPNL)
+	if b.Deal != nil {
+		b.Deal.ProceedsUSDT += costBasis/b.leverage() + realizedPNL
+		b.Deal.RealizedPNL += realizedPNL
+	}
 
 	// Filter out empty records
 	var updated []DCARecord
@@ -182,71 +765,162 @@ func (b *DCABot) executeSell(price float64, token string) {
 		}
 	}
 	b.Records = updated
+	b.saveState()
 
-	message := fmt.Sprintf("🔴 BYBIT SELL\nPrice: %.4f\nQty: %.6f\nRealized: %.2f", price, sellQty, realizedPNL)
-	sendTelegramMessage(token, message)
+	b.recordLedger(b.exitSide(), price, sellQty, costBasis, realizedPNL, method)
 }
 
 func StartDCAWebSocket(bot *DCABot, token string) {
 	go bot.StartDailyPNLTracker(token)
+	bot.startEntryFeed()
+	bot.startBookFeed()
+	bot.startOrderFeed()
+	go listenTelegramCommands(token, map[string]func() string{
+		"/status": bot.locked(bot.statusMessage),
+		"/resume": bot.locked(bot.resumeBuys),
+		"/deals":  bot.locked(bot.dealsReport),
+	})
 
 	for {
-		err := startBybitWS(bot, token)
-		log.Printf("Bybit WS Disconnected: %v. Reconnecting...", err)
+		err := bot.Exchange.StreamTrades(bot.Symbol, func(price float64) {
+			bot.mu.Lock()
+			defer bot.mu.Unlock()
+			bot.OnPrice(price, token)
+		})
+		log.Printf("%s WS Disconnected: %v. Reconnecting...", bot.Exchange.Name(), err)
 		time.Sleep(5 * time.Second)
 	}
 }
 
-func startBybitWS(bot *DCABot, token string) error {
-	wsURL := "wss://stream.bybit.com/v5/public/spot"
-	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
-	if err != nil {
-		return err
+// NewDCABotFromConfig builds a bot from a full config
+func NewDCABotFromConfig(exchange SpotExchange, cfg DCAConfig) *DCABot {
+	bot := NewDCABot(exchange, cfg.Symbol, cfg.TotalUSDT, cfg.DropPercent, cfg.SellPercent, cfg.FallbackBuyHours)
+	bot.BotID = dcaBotID(exchange.Name(), cfg.Environment, cfg.Symbol, cfg.DropPercent)
+	if cfg.BaseOrderUSDT > 0 {
+		bot.OneBuyUSDT = cfg.BaseOrderUSDT
 	}
-	defer c.Close()
+	bot.NativeTakeProfit = cfg.NativeTakeProfit
 
-	// 1. Subscribe to Trade Topic
-	sub := map[string]interface{}{
-		"op":   "subscribe",
-		"args": []string{"publicTrade." + bot.Symbol},
+	bot.BaseOrderUSDT = bot.OneBuyUSDT
+	bot.SafetyOrderUSDT = cfg.SafetyOrderUSDT
+	bot.MaxSafetyOrders = cfg.MaxSafetyOrders
+	if cfg.VolumeScale > 0 {
+		bot.VolumeScale = cfg.VolumeScale
 	}
-	if err := c.WriteJSON(sub); err != nil {
-		return err
+	if cfg.StepScale > 0 {
+		bot.StepScale = cfg.StepScale
 	}
 
-	// 2. Start Heartbeat (Ping) every 20s
-	go func() {
-		ticker := time.NewTicker(20 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := c.WriteJSON(map[string]string{"op": "ping"}); err != nil {
-				return
-			}
-		}
-	}()
+	// A resting limit sell would exit before the trail ever gets going
+	bot.TrailingDeviation = cfg.TrailingDeviation
+	if bot.trailingEnabled() {
+		bot.NativeTakeProfit = false
+	}
+	bot.TPLadder = cfg.TPLadder
 
-	fmt.Printf("✅ Bybit WS Connected for %s\n", bot.Symbol)
+	bot.StopLossPercent = cfg.StopLossPercent
+	bot.StopLossAction = cfg.StopLossAction
+	bot.MaxDrawdownUSDT = cfg.MaxDrawdownUSDT
+	bot.DrawdownAction = cfg.DrawdownAction
+	bot.FloorPrice = cfg.FloorPrice
+	bot.FloorAction = cfg.FloorAction
 
-	for {
-		var msg map[string]interface{}
-		if err := c.ReadJSON(&msg); err != nil {
-			return err
-		}
+	bot.EntryFilter = cfg.EntryFilter
 
-		// Bybit V5 Public Trade data structure
-		if data, ok := msg["data"].([]interface{}); ok && len(data) > 0 {
-			trade := data[0].(map[string]interface{})
-			if pStr, ok := trade["p"].(string); ok {
-				price, _ := strconv.ParseFloat(pStr, 64)
-				bot.OnPrice(price, token)
-			}
+	bot.Schedule = cfg.Schedule
+	bot.ScheduleUSDT = cfg.ScheduleUSDT
+	bot.BoostPercent = cfg.BoostPercent
+	bot.BoostMultiplier = cfg.BoostMultiplier
+	if bot.BoostMultiplier <= 0 {
+		bot.BoostMultiplier = 2
+	}
+	bot.CatchUp = cfg.CatchUp
+	if bot.CatchUp == "" {
+		bot.CatchUp = CatchUpOnce
+	}
+	if bot.Schedule != nil {
+		bot.BotID = scheduledBotID(exchange.Name(), cfg.Environment, cfg.Symbol, bot.Schedule)
+	}
+
+	bot.Reinvest = cfg.Reinvest
+	if bot.Reinvest == "" {
+		bot.Reinvest = ReinvestFixed
+	}
+	bot.VaultPercent = cfg.VaultPercent
+	bot.InitialUSDT = cfg.TotalUSDT
+
+	bot.DealCooldown = time.Duration(cfg.DealCooldownMinutes) * time.Minute
+	bot.StopAfterDeal = cfg.StopAfterDeal
+
+	bot.ValueStepUSDT = cfg.ValueStepUSDT
+	bot.MaxBuyUSDT = cfg.MaxBuyUSDT
+	bot.MaxSellUSDT = cfg.MaxSellUSDT
+	if bot.valueAveraging() {
+		bot.NativeTakeProfit = false // the value path does its own selling
+	}
+
+	if cfg.Short {
+		bot.Short = true
+		bot.Leverage = cfg.Leverage
+		bot.MarginMode = cfg.MarginMode
+		bot.LiqAlertPercent = cfg.LiqAlertPercent
+		bot.Futures, _ = exchange.(FuturesExchange)
+		bot.BotID = dcaBotID(exchange.Name()+"-short", cfg.Environment, cfg.Symbol, cfg.DropPercent)
+
+		// These assume a long spot position, a short exits on the price alone
+		bot.NativeTakeProfit = false
+		bot.TrailingDeviation = 0
+		bot.Schedule = nil
+		bot.ValueStepUSDT = 0
+	}
+
+	bot.ATRStep = cfg.ATRStep
+	bot.TrailingBuyRebound = cfg.TrailingBuyRebound
+	bot.TrailingBuyMaxWait = time.Duration(cfg.TrailingBuyMaxWaitMinutes) * time.Minute
+
+	bot.RegimeFilter = cfg.RegimeFilter
+	for _, rule := range []*RegimeRule{&bot.RegimeFilter.Bull, &bot.RegimeFilter.Range, &bot.RegimeFilter.Bear} {
+		if rule.DropScale <= 0 {
+			rule.DropScale = 1
+		}
+		if rule.SizeScale <= 0 {
+			rule.SizeScale = 1
 		}
 	}
+
+	bot.BookGuard = cfg.BookGuard
+	if bot.BookGuard.Action == "" {
+		bot.BookGuard.Action = BookDelay
+	}
+
+	bot.CostBasis = cfg.CostBasis
+	if bot.CostBasis == "" {
+		bot.CostBasis = CostFIFO
+	}
+	bot.ExitMode = cfg.ExitMode
+	if bot.ExitMode == "" {
+		bot.ExitMode = ExitAverage
+	}
+	if bot.ExitMode == ExitLot {
+		// One resting order or trail can't follow many targets
+		bot.NativeTakeProfit = false
+		bot.TPLadder = nil
+		bot.TrailingDeviation = 0
+	}
+	if bot.ATRStep.Period <= 0 {
+		bot.ATRStep.Period = 14
+	}
+	if feed, ok := exchange.(CandleFeed); ok {
+		bot.Candles = feed
+	}
+	return bot
 }
 
-func RunDCABot(client *bybit.Client, symbol string, totalUSDT, oneBuyUSDT, dropPercent, sellPercent float64, fallbackBuyHours int) {
-	bot := NewDCABot(client, symbol, totalUSDT, dropPercent, sellPercent, fallbackBuyHours)
-	bot.OneBuyUSDT = oneBuyUSDT
+func RunDCABot(exchange SpotExchange, account AccountService, store StateStore, cfg DCAConfig) {
+	bot := NewDCABotFromConfig(exchange, cfg)
+	bot.Store = store
+	bot.Account = account
+	fallbackBuyHours := cfg.FallbackBuyHours
 
 	tokenMap := constant.GetTokenMap()
 	tokenConfig, ok := tokenMap[bot.Symbol].(map[float64]string)
@@ -277,6 +951,8 @@ func RunDCABot(client *bybit.Client, symbol string, totalUSDT, oneBuyUSDT, dropP
 	default:
 	}
 
+	bot.restoreState(token)
+
 	StartDCAWebSocket(bot, token)
 }
 
@@ -314,8 +990,8 @@ func (b *DCABot) UnrealizedPNL(currentPrice float64) (pnlUSDT float64, pnlPercen
 		return 0, 0
 	}
 
-	pnlUSDT = (currentPrice - avg) * holdings
-	pnlPercent = ((currentPrice / avg) - 1) * 100
+	pnlUSDT = b.dir() * (currentPrice - avg) * holdings
+	pnlPercent = b.dir() * ((currentPrice / avg) - 1) * 100
 	return
 }
 
@@ -333,8 +1009,10 @@ func (b *DCABot) StartDailyPNLTracker(token string) {
 		// Sleep until 12:00 AM
 		time.Sleep(timeUntilMidnight)
 
+		b.mu.Lock()
 		// Skip if no holdings
 		if len(b.Records) == 0 {
+			b.mu.Unlock()
 			continue
 		}
 
@@ -352,9 +1030,109 @@ func (b *DCABot) StartDailyPNLTracker(token string) {
 			pnlUSDT,
 			pnlPercent,
 		)
+		if trailing := b.trailingStatus(); trailing != "" {
+			message += "\n" + trailing
+		}
+		if schedule := b.scheduleStatus(); schedule != "" {
+			message += "\n" + schedule
+		}
+		message += "\n" + b.dealStatus()
+		message += "\n" + b.reinvestStatus()
+		if short := b.shortStatus(); short != "" {
+			message += "\n" + short
+		}
+		if thresholds := b.atrStatus(); thresholds != "" {
+			message += "\n" + thresholds
+		}
+		if lots := b.lotStatus(); lots != "" {
+			message += "\n" + lots
+		}
+		if regime := b.regimeStatus(); regime != "" {
+			message += "\n" + regime
+		}
+		b.mu.Unlock()
 
 		sendTelegramMessage(token, message)
 
 		// Loop will repeat → next iteration calculates next midnight
 	}
 }
+
+// locked wraps a command handler so it runs between ticks, never in the
+// middle of one
+func (b *DCABot) locked(handler func() string) func() string {
+	return func() string {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return handler()
+	}
+}
+
+// statusMessage answers /status with where the bot stands right now
+func (b *DCABot) statusMessage() string {
+	price := b.LatestDayPrice
+	pnlUSDT, pnlPercent := b.UnrealizedPNL(price)
+	target, _ := b.nextTakeProfit()
+
+	message := fmt.Sprintf(
+		"ℹ️ %s DCA Status (%s)\nPrice: %.4f\nHoldings: %.6f\nAvg Entry: %.4f\nNext Target: %.4f\nUnrealized PNL: %.2f USDT (%.2f%%)\nRealized PNL: %.2f USDT\nUSDT Left: %.2f",
+		b.Symbol,
+		b.Exchange.Name(),
+		price,
+		b.totalHoldings(),
+		b.avgBuyPrice(),
+		target,
+		pnlUSDT,
+		pnlPercent,
+		b.RealizedPNL,
+		b.TotalUSDT,
+	)
+	message += "\n" + b.dealStatus()
+	message += "\n" + b.reinvestStatus()
+	if b.ExitMode != ExitLot {
+		message += fmt.Sprintf("\nCost basis: %s", b.CostBasis)
+	}
+	if short := b.shortStatus(); short != "" {
+		message += "\n" + short
+	}
+	if thresholds := b.atrStatus(); thresholds != "" {
+		message += "\n" + thresholds
+	}
+	if trailing := b.trailingBuyStatus(); trailing != "" {
+		message += "\n" + trailing
+	}
+	if lots := b.lotStatus(); lots != "" {
+		message += "\n" + lots
+	}
+	if regime := b.regimeStatus(); regime != "" {
+		message += "\n" + regime
+	}
+	if book := b.bookStatus(); book != "" {
+		message += "\n" + book
+	}
+	if b.safetyEnabled() {
+		message += fmt.Sprintf("\nSafety Orders: %d/%d", b.SafetyCount, b.MaxSafetyOrders)
+	}
+	if b.TPOrderID != "" {
+		message += fmt.Sprintf("\nTP Order: %.6f @ %.4f", b.TPOrderQty, b.TPOrderPrice)
+	}
+	if trailing := b.trailingStatus(); trailing != "" {
+		message += "\n" + trailing
+	}
+	if ladder := b.ladderStatus(); ladder != "" {
+		message += "\n" + ladder
+	}
+	if schedule := b.scheduleStatus(); schedule != "" {
+		message += "\n" + schedule
+	}
+	if entry := b.entryStatus(); entry != "" {
+		message += "\n" + entry
+	}
+	if protection := b.protectionStatus(); protection != "" {
+		message += "\n" + protection
+	}
+	if b.Paused {
+		message += "\n⏸️ Paused: " + b.PauseReason
+	}
+	return message
+}
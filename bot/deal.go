@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Deal Lifecycle
+////////////////////////////////////////////////////////////
+
+// Deal is one cycle of the bot, from the base order until the position is
+// sold off
+type Deal struct {
+	ID           string
+	Number       int
+	OpenedAt     time.Time
+	ClosedAt     time.Time
+	Buys         int
+	SafetyOrders int
+	LotSells     int     // lots sold on their own target
+	CapitalUSDT  float64 // spent on buys
+	ProceedsUSDT float64 // received from sells
+	RealizedPNL  float64
+}
+
+func (d Deal) Duration() time.Duration {
+	end := d.ClosedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(d.OpenedAt)
+}
+
+func (d Deal) ProfitPercent() float64 {
+	if d.CapitalUSDT == 0 {
+		return 0
+	}
+	return d.RealizedPNL / d.CapitalUSDT * 100
+}
+
+// openDeal starts a new deal for the buy about to go out
+func (b *DCABot) openDeal() {
+	b.Deal = &Deal{
+		ID:       fmt.Sprintf("%s-%d", b.BotID, b.DealNumber),
+		Number:   b.DealNumber,
+		OpenedAt: time.Now(),
+	}
+	b.saveState()
+}
+
+// checkDealClosed closes the open deal once nothing sellable is left. Dust
+// below the exchange minimum can never be sold, so it counts as flat.
+func (b *DCABot) checkDealClosed(price float64, token string) {
+	d := b.Deal
+	if d == nil || b.TPOrderID != "" || b.split != nil || len(b.Intents) > 0 {
+		return // the exit is still going out
+	}
+	if holdings := b.totalHoldings(); holdings > 0 {
+		if b.instrument == nil || holdings*price >= b.instrument.MinOrderAmt {
+			return
+		}
+	}
+	if d.Buys == 0 {
+		return // the base order never filled, the next one reuses the deal
+	}
+
+	d.ClosedAt = time.Now()
+	b.Deals = append(b.Deals, *d)
+	b.Deal = nil
+	b.LastDealClosed = d.ClosedAt
+
+	// Nothing of this deal may leak into the next one
+	b.Records = nil
+	b.Started = false
+	b.LastBuyPrice = 0
+	b.BasePrice = 0
+	b.SafetyCount = 0
+	b.TPLevel = 0
+	b.resetTrailing()
+	b.resetTrailingBuy()
+	b.saveState()
+
+	message := fmt.Sprintf("🏁 DEAL #%d CLOSED\nSymbol: %s\nDuration: %s\nBuys: %d (%d safety orders)\nCapital: %.2f USDT\nProfit: %.2f USDT (%.2f%%)",
+		d.Number, b.Symbol, formatDuration(d.Duration()), d.Buys, d.SafetyOrders, d.CapitalUSDT, d.RealizedPNL, d.ProfitPercent())
+	if d.LotSells > 0 {
+		message += fmt.Sprintf("\nLots sold: %d", d.LotSells)
+	}
+	switch {
+	case b.StopAfterDeal:
+		sendTelegramMessage(token, message)
+		b.pauseBuys(fmt.Sprintf("deal #%d closing", d.Number), token)
+		return
+	case b.DealCooldown > 0:
+		message += fmt.Sprintf("\nNext deal after %s", d.ClosedAt.Add(b.DealCooldown).Format("2006-01-02 15:04"))
+	}
+	sendTelegramMessage(token, message)
+}
+
+// dealBlocked holds a new deal back during the cooldown
+func (b *DCABot) dealBlocked() string {
+	if b.DealCooldown <= 0 || b.LastDealClosed.IsZero() || b.totalHoldings() > 0 {
+		return ""
+	}
+	if until := b.LastDealClosed.Add(b.DealCooldown); time.Now().Before(until) {
+		return fmt.Sprintf("cooling down until %s", until.Format("15:04"))
+	}
+	return ""
+}
+
+// dealStatus is the deal line for /status and the daily report
+func (b *DCABot) dealStatus() string {
+	closed := len(b.Deals)
+	profit := 0.0
+	for _, d := range b.Deals {
+		profit += d.RealizedPNL
+	}
+
+	status := fmt.Sprintf("Deals: %d closed, %.2f USDT profit", closed, profit)
+	if d := b.Deal; d != nil {
+		status += fmt.Sprintf("\nDeal #%d open %s, %d buys, %.2f USDT in", d.Number, formatDuration(d.Duration()), d.Buys, d.CapitalUSDT)
+	}
+	return status
+}
+
+// dealsReport answers /deals with totals and the latest closed deals
+func (b *DCABot) dealsReport() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📒 %s Deals\n", b.Symbol)
+
+	if len(b.Deals) == 0 {
+		sb.WriteString("No closed deals yet")
+	} else {
+		var profit, capital float64
+		var wins int
+		var total time.Duration
+		for _, d := range b.Deals {
+			profit += d.RealizedPNL
+			capital += d.CapitalUSDT
+			total += d.Duration()
+			if d.RealizedPNL > 0 {
+				wins++
+			}
+		}
+		n := len(b.Deals)
+		fmt.Fprintf(&sb, "Closed: %d (%d in profit)\nProfit: %.2f USDT\nAvg capital: %.2f USDT\nAvg duration: %s\n",
+			n, wins, profit, capital/float64(n), formatDuration(total/time.Duration(n)))
+
+		for i := n - 1; i >= 0 && i >= n-5; i-- {
+			d := b.Deals[i]
+			fmt.Fprintf(&sb, "\n#%d %s → %s\n%d buys, %d SO, %.2f USDT in, %.2f USDT (%.2f%%)",
+				d.Number, d.OpenedAt.Format("01-02 15:04"), d.ClosedAt.Format("01-02 15:04"),
+				d.Buys, d.SafetyOrders, d.CapitalUSDT, d.RealizedPNL, d.ProfitPercent())
+		}
+	}
+
+	if d := b.Deal; d != nil {
+		pnlUSDT, pnlPercent := b.UnrealizedPNL(b.LatestDayPrice)
+		fmt.Fprintf(&sb, "\n\nOpen #%d since %s\n%d buys, %d SO, %.2f USDT in\nRealized: %.2f USDT\nUnrealized: %.2f USDT (%.2f%%)",
+			d.Number, d.OpenedAt.Format("01-02 15:04"), d.Buys, d.SafetyOrders, d.CapitalUSDT, d.RealizedPNL, pnlUSDT, pnlPercent)
+	}
+	return sb.String()
+}
+
+// formatDuration prints durations as 3d4h, 5h12m or 7m
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
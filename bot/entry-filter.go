@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Indicator Entry Filters
+////////////////////////////////////////////////////////////
+
+// EntryFilter holds buys back until the indicators on Interval candles agree.
+// Every check is off at its zero value.
+type EntryFilter struct {
+	Interval     string
+	RSIBelow     float64 // RSI(14) of closed candles must be under this
+	BollingerLow bool    // last close must be under the lower band
+	EMALength    int
+	EMASide      string // "above" or "below", where the price must sit against the EMA
+}
+
+func ParseEMASide(s string) (string, error) {
+	switch side := strings.ToLower(strings.TrimSpace(s)); side {
+	case "":
+		return "above", nil
+	case "above", "below":
+		return side, nil
+	}
+	return "", fmt.Errorf("unknown EMA side %q, use above or below", s)
+}
+
+func (f *EntryFilter) enabled() bool {
+	return f.RSIBelow > 0 || f.BollingerLow || f.EMALength > 0
+}
+
+// String lists the active checks for the startup printout and /status
+func (f EntryFilter) String() string {
+	if !f.enabled() {
+		return "off"
+	}
+	var parts []string
+	if f.RSIBelow > 0 {
+		parts = append(parts, fmt.Sprintf("RSI(%d) < %.0f", rsiLength, f.RSIBelow))
+	}
+	if f.BollingerLow {
+		parts = append(parts, fmt.Sprintf("close < lower BB(%d, %.0f)", bbLength, bbMult))
+	}
+	if f.EMALength > 0 {
+		parts = append(parts, fmt.Sprintf("price %s EMA(%d)", f.EMASide, f.EMALength))
+	}
+	return fmt.Sprintf("%s on %s candles", strings.Join(parts, ", "), f.Interval)
+}
+
+// startEntryFeed loads recent candles for the filters and keeps them up to
+// date in the background
+func (b *DCABot) startEntryFeed() {
+	if !b.EntryFilter.enabled() {
+		return
+	}
+	if b.Candles == nil {
+		log.Printf("%s has no candle feed, entry filters will hold every buy", b.Exchange.Name())
+		return
+	}
+
+	history, err := b.Candles.Candles(b.Symbol, b.EntryFilter.Interval, 300)
+	if err != nil {
+		log.Printf("%s entry filter candles error: %v", b.Symbol, err)
+	}
+	b.candleMu.Lock()
+	for _, c := range history {
+		b.closes = append(b.closes, c.Close)
+	}
+	b.candleMu.Unlock()
+
+	go func() {
+		for {
+			err := b.Candles.StreamCandles(b.Symbol, b.EntryFilter.Interval, func(c Candle) {
+				b.candleMu.Lock()
+				b.closes = append(b.closes, c.Close)
+				if len(b.closes) > 500 {
+					b.closes = b.closes[len(b.closes)-500:]
+				}
+				b.candleMu.Unlock()
+			})
+			log.Printf("%s candle WS disconnected: %v. Reconnecting...", b.Exchange.Name(), err)
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+// entryBlocked reports which filter holds a buy at price back, if any
+func (b *DCABot) entryBlocked(price float64) string {
+	f := &b.EntryFilter
+	if !f.enabled() {
+		return ""
+	}
+
+	b.candleMu.Lock()
+	closes := append([]float64(nil), b.closes...)
+	b.candleMu.Unlock()
+
+	if f.RSIBelow > 0 {
+		if len(closes) < rsiLength+1 {
+			return "not enough candles for RSI yet"
+		}
+		if rsi := calcRSI(closes, rsiLength); rsi >= f.RSIBelow {
+			return fmt.Sprintf("RSI %.1f is not below %.0f", rsi, f.RSIBelow)
+		}
+	}
+
+	if f.BollingerLow {
+		if len(closes) < bbLength {
+			return "not enough candles for Bollinger Bands yet"
+		}
+		lower := bollingerLower(closes)
+		if last := closes[len(closes)-1]; last >= lower {
+			return fmt.Sprintf("close %.4f is not under the lower band %.4f", last, lower)
+		}
+	}
+
+	if f.EMALength > 0 {
+		if len(closes) < f.EMALength {
+			return fmt.Sprintf("not enough candles for EMA(%d) yet", f.EMALength)
+		}
+		e := ema(closes, f.EMALength)
+		if f.EMASide == "below" && price >= e {
+			return fmt.Sprintf("price %.4f is not below EMA(%d) %.4f", price, f.EMALength, e)
+		}
+		if f.EMASide != "below" && price <= e {
+			return fmt.Sprintf("price %.4f is not above EMA(%d) %.4f", price, f.EMALength, e)
+		}
+	}
+	return ""
+}
+
+// logSkippedBuy logs a held back buy and why. The same reason is repeated
+// at most once a minute since a drop keeps triggering on every tick.
+func (b *DCABot) logSkippedBuy(kind string, price float64, reason string) {
+	if reason == b.lastSkip && time.Since(b.lastSkipLog) < time.Minute {
+		return
+	}
+	b.lastSkip = reason
+	b.lastSkipLog = time.Now()
+	log.Printf("%s %s at %.4f skipped: %s", b.Symbol, kind, price, reason)
+}
+
+// entryStatus is the filter line for /status
+func (b *DCABot) entryStatus() string {
+	if !b.EntryFilter.enabled() {
+		return ""
+	}
+	status := "Entry filter: " + b.EntryFilter.String()
+	if reason := b.entryBlocked(b.LatestDayPrice); reason != "" {
+		return status + "\nHolding buys: " + reason
+	}
+	return status + "\nFilters agree"
+}
+
+func bollingerLower(closes []float64) float64 {
+	mean := sma(closes, bbLength)
+	return mean - bbMult*stddev(closes[len(closes)-bbLength:], mean)
+}
+
+// ema is the exponential moving average of data, seeded with the SMA of the
+// first length values
+func ema(data []float64, length int) float64 {
+	if len(data) < length {
+		return 0
+	}
+	k := 2 / float64(length+1)
+	e := sma(data[:length], length)
+	for _, v := range data[length:] {
+		e = v*k + e*(1-k)
+	}
+	return e
+}
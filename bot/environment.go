@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+)
+
+// Environment is a consistent set of REST and WebSocket hosts for both
+// exchanges, so a bot never trades on testnet while watching mainnet prices
+type Environment struct {
+	Name string
+
+	BybitREST     string
+	BybitSpotWS   string
+	BybitLinearWS string
+
+	BinanceFuturesREST string
+	BinanceFuturesWS   string
+	BinanceSpotREST    string
+	BinanceSpotWS      string
+
+	// OKX demo trading shares the REST host and is selected by a header
+	OKXREST       string
+	OKXPublicWS   string
+	OKXPrivateWS  string
+	OKXBusinessWS string
+	OKXSimulated  bool
+}
+
+var mainnetHosts = Environment{
+	BinanceFuturesREST: "https://fapi.binance.com",
+	BinanceFuturesWS:   "wss://fstream.binance.com",
+	BinanceSpotREST:    "https://api.binance.com",
+	BinanceSpotWS:      "wss://stream.binance.com:9443",
+
+	OKXREST:       "https://www.okx.com",
+	OKXPublicWS:   "wss://ws.okx.com:8443/ws/v5/public",
+	OKXPrivateWS:  "wss://ws.okx.com:8443/ws/v5/private",
+	OKXBusinessWS: "wss://ws.okx.com:8443/ws/v5/business",
+}
+
+var okxDemo = Environment{
+	OKXREST:       "https://www.okx.com",
+	OKXPublicWS:   "wss://wspap.okx.com:8443/ws/v5/public",
+	OKXPrivateWS:  "wss://wspap.okx.com:8443/ws/v5/private",
+	OKXBusinessWS: "wss://wspap.okx.com:8443/ws/v5/business",
+	OKXSimulated:  true,
+}
+
+// bybitRegion uses Bybit's regional domains with the other exchanges' mainnet
+func bybitRegion(name, domain string) Environment {
+	env := mainnetHosts
+	env.Name = name
+	env.BybitREST = "https://api." + domain
+	env.BybitSpotWS = "wss://stream." + domain + "/v5/public/spot"
+	env.BybitLinearWS = "wss://stream." + domain + "/v5/public/linear"
+	return env
+}
+
+var environments = map[string]Environment{
+	"mainnet": bybitRegion("mainnet", "bybit.com"),
+	"testnet": {
+		Name:               "testnet",
+		BybitREST:          bybit.TESTNET,
+		BybitSpotWS:        bybit.SPOT_TESTNET,
+		BybitLinearWS:      bybit.LINEAR_TESTNET,
+		BinanceFuturesREST: "https://testnet.binancefuture.com",
+		BinanceFuturesWS:   "wss://fstream.binancefuture.com",
+		BinanceSpotREST:    "https://testnet.binance.vision",
+		BinanceSpotWS:      "wss://stream.testnet.binance.vision",
+		OKXREST:            okxDemo.OKXREST,
+		OKXPublicWS:        okxDemo.OKXPublicWS,
+		OKXPrivateWS:       okxDemo.OKXPrivateWS,
+		OKXBusinessWS:      okxDemo.OKXBusinessWS,
+		OKXSimulated:       true,
+	},
+	"demo": func() Environment {
+		// Demo trading runs on mainnet market data
+		env := bybitRegion("demo", "bybit.com")
+		env.BybitREST = bybit.DEMO_ENV
+		env.OKXPublicWS = okxDemo.OKXPublicWS
+		env.OKXPrivateWS = okxDemo.OKXPrivateWS
+		env.OKXBusinessWS = okxDemo.OKXBusinessWS
+		env.OKXSimulated = true
+		return env
+	}(),
+	"tr": bybitRegion("tr", "bybit-tr.com"),
+	"nl": bybitRegion("nl", "bybit.nl"),
+	"eu": bybitRegion("eu", "bybit.eu"),
+	"hk": bybitRegion("hk", "byhkbit.com"),
+	"kz": bybitRegion("kz", "bybit.kz"),
+}
+
+// EnvironmentNames lists the accepted names for prompts and errors
+func EnvironmentNames() string {
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func LookupEnvironment(name string) (Environment, error) {
+	env, ok := environments[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Environment{}, fmt.Errorf("unknown environment %q, use one of: %s", name, EnvironmentNames())
+	}
+	return env, nil
+}
+
+// NewBybitClient builds a client for env with the timeout, clock sync and
+// rate limiting every bot expects
+func NewBybitClient(env Environment, apiKey, apiSecret string) *bybit.Client {
+	client := bybit.NewBybitHttpClient(apiKey, apiSecret, bybit.WithBaseURL(env.BybitREST))
+	client.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+	clock := NewBybitClock(client)
+	if err := clock.Sync(); err != nil {
+		log.Printf("Bybit clock sync error: %v", err)
+	} else {
+		log.Printf("Bybit %s clock offset: %v", env.Name, clock.Offset())
+	}
+	UseBybitClock(client, clock)
+	UseBybitRateLimit(client)
+	go clock.Run()
+
+	return client
+}
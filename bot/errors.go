@@ -0,0 +1,373 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+	"github.com/bybit-exchange/bybit.go.api/handlers"
+)
+
+// ErrorKind groups exchange error codes by what the bot should do about them
+type ErrorKind int
+
+const (
+	ErrUnknown ErrorKind = iota
+	ErrNetwork
+	ErrRejected
+	ErrInsufficientBalance
+	ErrMinNotional
+	ErrRateLimit
+	ErrInvalidTimestamp
+	ErrMaintenance
+	ErrAuth
+	ErrDuplicateOrder
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrNetwork:
+		return "network"
+	case ErrRejected:
+		return "rejected"
+	case ErrInsufficientBalance:
+		return "insufficient balance"
+	case ErrMinNotional:
+		return "below min notional"
+	case ErrRateLimit:
+		return "rate limited"
+	case ErrInvalidTimestamp:
+		return "invalid timestamp"
+	case ErrMaintenance:
+		return "maintenance"
+	case ErrAuth:
+		return "auth"
+	case ErrDuplicateOrder:
+		return "duplicate order id"
+	default:
+		return "unknown"
+	}
+}
+
+// ExchangeError is a REST failure from Bybit or Binance, classified by kind
+type ExchangeError struct {
+	Exchange string
+	Kind     ErrorKind
+	Code     int
+	Status   int
+	Message  string
+	Err      error
+}
+
+func (e *ExchangeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s: %v", e.Exchange, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s %s (code %d): %s", e.Exchange, e.Kind, e.Code, e.Message)
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+// Transient errors go away on their own and are worth retrying
+func (e *ExchangeError) Transient() bool {
+	switch e.Kind {
+	case ErrNetwork, ErrRateLimit, ErrInvalidTimestamp, ErrMaintenance:
+		return true
+	case ErrUnknown:
+		return e.Status >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// Fatal errors will keep failing until someone looks at the account. A size
+// below the minimum only concerns that order and is handled by the caller.
+func (e *ExchangeError) Fatal() bool {
+	switch e.Kind {
+	case ErrAuth, ErrInsufficientBalance:
+		return true
+	}
+	return false
+}
+
+// Answered reports whether the exchange processed the request and replied,
+// as opposed to the request getting lost on the way
+func (e *ExchangeError) Answered() bool {
+	return e.Kind != ErrNetwork && e.Status < http.StatusInternalServerError
+}
+
+func asExchangeError(err error) (*ExchangeError, bool) {
+	var exErr *ExchangeError
+	ok := errors.As(err, &exErr)
+	return exErr, ok
+}
+
+////////////////////////////////////////////////////////////
+// Bybit
+////////////////////////////////////////////////////////////
+
+func bybitErrorKind(code int, msg string) ErrorKind {
+	switch code {
+	case 10002:
+		return ErrInvalidTimestamp
+	case 10003, 10004, 10005, 10007, 10009, 10010, 33004:
+		return ErrAuth
+	case 10006, 10018, 10429:
+		return ErrRateLimit
+	case 10016, 10019:
+		return ErrMaintenance
+	case 110004, 110007, 110012, 170131, 170033, 170036:
+		return ErrInsufficientBalance
+	case 110094, 170136, 170137, 170140:
+		return ErrMinNotional
+	case 110072:
+		return ErrDuplicateOrder
+	}
+
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "insufficient"):
+		return ErrInsufficientBalance
+	case strings.Contains(lower, "too many"), strings.Contains(lower, "rate limit"):
+		return ErrRateLimit
+	case strings.Contains(lower, "maintenance"):
+		return ErrMaintenance
+	}
+	return ErrRejected
+}
+
+// bybitError turns a non-zero retCode into an ExchangeError
+func bybitError(res *bybit.ServerResponse) error {
+	if res == nil {
+		return &ExchangeError{Exchange: "bybit", Kind: ErrUnknown, Message: "empty response"}
+	}
+	if res.RetCode == 0 {
+		return nil
+	}
+	return &ExchangeError{
+		Exchange: "bybit",
+		Kind:     bybitErrorKind(res.RetCode, res.RetMsg),
+		Code:     res.RetCode,
+		Message:  res.RetMsg,
+	}
+}
+
+// wrapBybitErr classifies the error returned by the Bybit client itself,
+// which is either an HTTP error body or a transport failure
+func wrapBybitErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := asExchangeError(err); ok {
+		return err
+	}
+
+	var apiErr *handlers.APIError
+	if errors.As(err, &apiErr) {
+		kind := bybitErrorKind(int(apiErr.Code), apiErr.Message)
+		if apiErr.Code == 0 {
+			// an IP ban comes back as a bare 403 without a JSON body
+			kind = ErrRateLimit
+		}
+		return &ExchangeError{Exchange: "bybit", Kind: kind, Code: int(apiErr.Code), Message: apiErr.Message}
+	}
+	return &ExchangeError{Exchange: "bybit", Kind: ErrNetwork, Err: err}
+}
+
+////////////////////////////////////////////////////////////
+// Binance
+////////////////////////////////////////////////////////////
+
+func binanceErrorKind(status, code int, msg string) ErrorKind {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusTeapot:
+		return ErrRateLimit
+	case http.StatusUnauthorized:
+		return ErrAuth
+	}
+
+	switch code {
+	case -1003, -1015:
+		return ErrRateLimit
+	case -1021:
+		return ErrInvalidTimestamp
+	case -1002, -1022, -2014, -2015:
+		return ErrAuth
+	case -1001, -1016:
+		return ErrMaintenance
+	case -2018, -2019:
+		return ErrInsufficientBalance
+	case -4164:
+		return ErrMinNotional
+	}
+
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "insufficient"):
+		return ErrInsufficientBalance
+	case strings.Contains(lower, "notional"):
+		return ErrMinNotional
+	case strings.Contains(lower, "duplicate order"):
+		return ErrDuplicateOrder
+	}
+
+	if status >= http.StatusInternalServerError {
+		return ErrUnknown
+	}
+	return ErrRejected
+}
+
+// binanceError reads the {code,msg} body Binance sends with every failed call
+func binanceError(status int, body []byte) error {
+	if status < http.StatusBadRequest {
+		return nil
+	}
+
+	var data struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil || data.Msg == "" {
+		data.Msg = strings.TrimSpace(string(body))
+	}
+
+	return &ExchangeError{
+		Exchange: "binance",
+		Kind:     binanceErrorKind(status, data.Code, data.Msg),
+		Code:     data.Code,
+		Status:   status,
+		Message:  data.Msg,
+	}
+}
+
+////////////////////////////////////////////////////////////
+// OKX
+////////////////////////////////////////////////////////////
+
+func okxErrorKind(status, code int, msg string) ErrorKind {
+	switch status {
+	case http.StatusTooManyRequests:
+		return ErrRateLimit
+	case http.StatusUnauthorized:
+		return ErrAuth
+	}
+
+	switch code {
+	case 50011, 50061:
+		return ErrRateLimit
+	case 50102:
+		return ErrInvalidTimestamp
+	case 50100, 50101, 50103, 50104, 50105, 50111, 50112, 50113, 50114:
+		return ErrAuth
+	case 50001, 50013, 50026:
+		return ErrMaintenance
+	case 51008, 51131:
+		return ErrInsufficientBalance
+	case 51020:
+		return ErrMinNotional
+	case 51016:
+		return ErrDuplicateOrder
+	}
+
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "insufficient"):
+		return ErrInsufficientBalance
+	case strings.Contains(lower, "too many"):
+		return ErrRateLimit
+	}
+
+	if status >= http.StatusInternalServerError {
+		return ErrUnknown
+	}
+	return ErrRejected
+}
+
+// okxError reads the {code,msg,data} envelope. Order calls report the real
+// reason per item in sCode/sMsg under a generic top-level code.
+func okxError(status int, body []byte) error {
+	var data struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		if status < http.StatusBadRequest {
+			return &ExchangeError{Exchange: "okx", Kind: ErrUnknown, Status: status, Message: "unreadable response", Err: err}
+		}
+		data.Msg = strings.TrimSpace(string(body))
+	}
+	if status < http.StatusBadRequest && data.Code == "0" {
+		return nil
+	}
+
+	code, msg := data.Code, data.Msg
+	var items []struct {
+		SCode string `json:"sCode"`
+		SMsg  string `json:"sMsg"`
+	}
+	json.Unmarshal(data.Data, &items) // market data rows are arrays, they carry no sCode
+	if len(items) > 0 && items[0].SCode != "" && items[0].SCode != "0" {
+		code, msg = items[0].SCode, items[0].SMsg
+	}
+	n, _ := strconv.Atoi(code)
+
+	return &ExchangeError{
+		Exchange: "okx",
+		Kind:     okxErrorKind(status, n, msg),
+		Code:     n,
+		Status:   status,
+		Message:  msg,
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Retry Policy
+////////////////////////////////////////////////////////////
+
+const (
+	retryAttempts = 4
+	retryBase     = 500 * time.Millisecond
+	retryMax      = 30 * time.Second
+)
+
+// backoff is exponential with jitter; maintenance waits much longer
+func backoff(attempt int, err error) time.Duration {
+	d := retryBase << attempt
+	if exErr, ok := asExchangeError(err); ok && exErr.Kind == ErrMaintenance {
+		d *= 10
+	}
+	if d > retryMax {
+		d = retryMax
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// withRetry runs fn until it succeeds, hits a non-transient error or runs
+// out of attempts
+func withRetry(name string, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < retryAttempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		exErr, ok := asExchangeError(err)
+		if !ok || !exErr.Transient() {
+			return err
+		}
+		resyncAfter(err)
+		wait := backoff(attempt, err)
+		log.Printf("%s failed (attempt %d), retrying in %v: %v", name, attempt+1, wait, err)
+		time.Sleep(wait)
+	}
+	return err
+}
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"dca-bot/config"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errOrderNotFound    = errors.New("order not found")
+	errAmendUnsupported = errors.New("amend not supported")
+)
+
+// Order statuses, normalised to Bybit's names
+const (
+	OrderNew             = "New"
+	OrderPartiallyFilled = "PartiallyFilled"
+	OrderFilled          = "Filled"
+	OrderCancelled       = "Cancelled"
+	OrderRejected        = "Rejected"
+)
+
+// SpotExchange is what the DCA bot needs from a venue: instrument rules,
+// orders and a trade feed
+type SpotExchange interface {
+	Name() string
+	Instrument(symbol string) (*Instrument, error)
+
+	// PlaceOrder tags the order with req.LinkID so it can be found again
+	PlaceOrder(req OrderRequest) (*Order, error)
+	AmendOrder(symbol, orderID, qty, price string) error
+	CancelOrder(symbol, orderID string) error
+	GetOrder(symbol, orderID string) (*Order, error)
+	GetOrderByLinkID(symbol, linkID string) (*Order, error)
+
+	// StreamTrades calls onPrice for every public trade until the
+	// connection drops
+	StreamTrades(symbol string, onPrice func(price float64)) error
+}
+
+// CandleFeed serves the candles the signal and grid bots run on
+type CandleFeed interface {
+	// Candles returns up to limit closed candles, oldest first
+	Candles(symbol, interval string, limit int) ([]Candle, error)
+
+	// StreamCandles calls onCandle for every closed candle until the
+	// connection drops
+	StreamCandles(symbol, interval string, onCandle func(Candle)) error
+}
+
+// SignalOrderer places the signal bot's market orders on the venue its
+// candles come from
+type SignalOrderer interface {
+	PlaceSignalOrder(symbol, side string, qty float64) error
+}
+
+// OrderStream pushes our order updates as they happen
+type OrderStream interface {
+	// StreamOrders calls onOrder for every update until the connection
+	// drops
+	StreamOrders(onOrder func(*Order)) error
+}
+
+// OrderBookFeed streams the live order book market orders are checked
+// against
+type OrderBookFeed interface {
+	// StreamOrderBook keeps book up to date until the connection drops
+	StreamOrderBook(symbol string, book *OrderBook) error
+}
+
+// Instrument holds the lot and price rules orders must respect
+type Instrument struct {
+	TickSize    float64
+	QtyStep     float64
+	MinOrderQty float64
+	MinOrderAmt float64 // min notional in quote coin
+}
+
+// OrderRequest is a spot order. Market buys are sized in quote coin with
+// QuoteQty, everything else in base coin with Qty.
+type OrderRequest struct {
+	Symbol   string
+	Side     string // Buy or Sell
+	Type     string // Market or Limit
+	Qty      string
+	QuoteQty string
+	Price    string
+	LinkID   string
+
+	// Only shrink the position, perpetuals only
+	ReduceOnly bool
+
+	// Limit orders rest until cancelled (GTC) unless set, IOC fills what it
+	// can right away and cancels the rest
+	TimeInForce string
+}
+
+type Order struct {
+	ID          string
+	LinkID      string
+	Status      string
+	Price       float64
+	Qty         float64
+	FilledQty   float64
+	FilledValue float64
+	AvgPrice    float64
+	Created     time.Time
+}
+
+// Open reports whether the order can still fill
+func (o *Order) Open() bool {
+	return o.Status == OrderNew || o.Status == OrderPartiallyFilled
+}
+
+// Value is the quote value filled, from the average price when the exchange
+// doesn't report it
+func (o *Order) Value() float64 {
+	if o.FilledValue > 0 {
+		return o.FilledValue
+	}
+	return o.FilledQty * o.AvgPrice
+}
+
+// CheckAPIKeys reports the keys missing for the exchange a bot is about to
+// use, the other exchanges' keys may stay empty
+func CheckAPIKeys(name string) error {
+	var missing []string
+	need := func(env, value string) {
+		if value == "" {
+			missing = append(missing, env)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "bybit":
+		need("BYBIT_API_KEY", config.BybitApiKey)
+		need("BYBIT_API_SECRET", config.BybitApiSecret)
+	case "binance":
+		need("BINANCE_API_KEY", config.BinanceApiKey)
+		need("BINANCE_API_SECRET", config.BinanceApiSecret)
+	case "okx":
+		need("OKX_API_KEY", config.OKXApiKey)
+		need("OKX_API_SECRET", config.OKXApiSecret)
+		need("OKX_PASSPHRASE", config.OKXPassphrase)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s credentials missing: %s, check your config loading", name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// NewSpotExchange connects to a spot venue by name with the configured keys
+func NewSpotExchange(name string, env Environment) (SpotExchange, AccountService, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "bybit":
+		client := NewBybitClient(env, config.BybitApiKey, config.BybitApiSecret)
+		return NewBybitExchange(client, env, "spot"), NewBybitAccount(client, "UNIFIED"), nil
+	case "binance":
+		return NewBinanceSpot(env), NewBinanceSpotAccount(env), nil
+	case "okx":
+		okx := NewOKX(env, config.OKXApiKey, config.OKXApiSecret, config.OKXPassphrase)
+		return okx, NewOKXAccount(okx), nil
+	}
+	return nil, nil, fmt.Errorf("unknown exchange %q, use bybit, binance or okx", name)
+}
+
+// FuturesExchange is implemented by exchanges that also trade perpetuals
+type FuturesExchange interface {
+	SetLeverage(symbol string, leverage float64) error
+
+	// SetMarginMode sets the margin of symbol's positions, MarginKeep
+	// leaves the account as it is
+	SetMarginMode(symbol string, mode MarginMode, leverage float64) error
+
+	// Position returns the open position on symbol held through side (Buy
+	// or Sell) orders, Size 0 when flat
+	Position(symbol, side string) (*Position, error)
+
+	// FundingSince returns the funding settled on symbol after since,
+	// oldest first
+	FundingSince(symbol string, since time.Time) ([]Funding, error)
+}
+
+// MarginMode is cross or isolated margin, or whatever the account uses
+type MarginMode string
+
+const (
+	MarginKeep     MarginMode = "keep"
+	MarginCross    MarginMode = "cross"
+	MarginIsolated MarginMode = "isolated"
+)
+
+func ParseMarginMode(s string) (MarginMode, error) {
+	switch m := MarginMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return MarginKeep, nil
+	case MarginKeep, MarginCross, MarginIsolated:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown margin mode %q, use keep, cross or isolated", s)
+}
+
+func (m MarginMode) String() string {
+	if m == MarginKeep || m == "" {
+		return "current"
+	}
+	return string(m)
+}
+
+type Position struct {
+	Side          string // Buy or Sell
+	Size          float64
+	AvgPrice      float64
+	MarkPrice     float64
+	LiqPrice      float64
+	Leverage      float64
+	UnrealizedPNL float64
+}
+
+// Funding is one funding settlement, positive when it was paid to us
+type Funding struct {
+	Time   time.Time
+	Amount float64
+}
+
+// NewFuturesExchange connects to the perpetuals side of an exchange by name
+func NewFuturesExchange(name string, env Environment) (SpotExchange, FuturesExchange, AccountService, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "bybit":
+		client := NewBybitClient(env, config.BybitApiKey, config.BybitApiSecret)
+		linear := NewBybitExchange(client, env, "linear")
+		return linear, linear, NewBybitAccount(client, "UNIFIED"), nil
+	}
+	return nil, nil, nil, fmt.Errorf("perpetuals on %q are not supported, use bybit", name)
+}
+
+// NewCandleFeed connects to a candle source by name
+func NewCandleFeed(name string, env Environment) (CandleFeed, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "binance":
+		return NewBinanceFutures(env), nil
+	case "okx":
+		return NewOKX(env, config.OKXApiKey, config.OKXApiSecret, config.OKXPassphrase), nil
+	}
+	return nil, fmt.Errorf("unknown exchange %q, use binance or okx", name)
+}
+
+// intervalDuration reads Binance-style intervals such as 15m, 4h, 1d, 1w or
+// 1M, a month counting as 30 days
+func intervalDuration(interval string) time.Duration {
+	if len(interval) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil {
+		return 0
+	}
+	switch interval[len(interval)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute
+	case 'h', 'H':
+		return time.Duration(n) * time.Hour
+	case 'd', 'D':
+		return time.Duration(n) * 24 * time.Hour
+	case 'w', 'W':
+		return time.Duration(n) * 7 * 24 * time.Hour
+	case 'M':
+		return time.Duration(n) * 30 * 24 * time.Hour
+	}
+	return 0
+}
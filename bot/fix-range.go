@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strings"
 	"sync"
 	"time"
 )
@@ -298,3 +299,30 @@ func (b *FixRangeBot) UnrealizedPNL() float64 {
 	}
 	return sum
 }
+
+////////////////////////////////////////////////////////////
+// Runner
+////////////////////////////////////////////////////////////
+
+// RunFixRangeBot drives the grid from the closed candles of feed, seeding
+// enough history for the first ATR
+func RunFixRangeBot(b *FixRangeBot, feed CandleFeed, interval string) {
+	history, err := feed.Candles(b.Symbol, interval, b.ATRPeriod*3)
+	if err != nil {
+		log.Printf("%s candle history error: %v", b.Symbol, err)
+	}
+	b.mu.Lock()
+	for _, c := range history {
+		b.Candles = append(b.Candles, FixRangeCandle{High: c.High, Low: c.Low, Close: c.Close})
+	}
+	b.mu.Unlock()
+
+	for {
+		err := feed.StreamCandles(b.Symbol, interval, func(c Candle) {
+			// the alert tokens are keyed by the lower case symbol
+			b.OnPrice(strings.ToLower(b.Symbol), c.Close, FixRangeCandle{High: c.High, Low: c.Low, Close: c.Close})
+		})
+		log.Printf("%s candle feed disconnected: %v. Reconnecting...", b.Symbol, err)
+		time.Sleep(5 * time.Second)
+	}
+}
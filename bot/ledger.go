@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Trade Ledger
+////////////////////////////////////////////////////////////
+
+// LedgerStore keeps the exported trade ledger, one row per fill or funding
+// payment. Stores without it simply keep no ledger.
+type LedgerStore interface {
+	AppendLedger(id string, header, row []string) error
+}
+
+var ledgerHeader = []string{
+	"time", "deal", "side", "price", "qty", "value_usdt",
+	"cost_basis_usdt", "realized_pnl", "method", "holdings_after", "avg_cost_after",
+}
+
+// recordLedger appends a fill with the cost it was matched against. Buys
+// carry their own cost and no PNL, method names how a sell was matched.
+func (b *DCABot) recordLedger(side string, price, qty, costBasis, realizedPNL float64, method string) {
+	store, ok := b.Store.(LedgerStore)
+	if !ok {
+		return
+	}
+	deal := ""
+	if b.Deal != nil {
+		deal = b.Deal.ID
+	}
+	row := []string{
+		time.Now().UTC().Format(time.RFC3339),
+		deal,
+		side,
+		formatLedger(price),
+		formatLedger(qty),
+		formatLedger(price * qty),
+		formatLedger(costBasis),
+		formatLedger(realizedPNL),
+		method,
+		formatLedger(b.totalHoldings()),
+		formatLedger(b.avgBuyPrice()),
+	}
+	if err := store.AppendLedger(b.BotID, ledgerHeader, row); err != nil {
+		log.Printf("%s ledger error: %v", b.Symbol, err)
+	}
+}
+
+func formatLedger(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////
+// Lot-Based Exits
+////////////////////////////////////////////////////////////
+
+// ExitMode decides what the take profit is measured against
+type ExitMode string
+
+const (
+	ExitAverage ExitMode = "average" // the whole position against the blended average
+	ExitLot     ExitMode = "lot"     // every buy on its own, at its own target
+)
+
+func ParseExitMode(s string) (ExitMode, error) {
+	switch m := ExitMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return ExitAverage, nil
+	case ExitAverage, ExitLot:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown exit mode %q, use average or lot", s)
+}
+
+// lotTarget is where a single buy takes its profit
+func (b *DCABot) lotTarget(r DCARecord) float64 {
+	return r.Price * (1 + b.dir()*b.sellStep()/100)
+}
+
+// checkLotExits sells every lot whose own target the price has reached, in
+// one order, while the rest of the position keeps waiting
+func (b *DCABot) checkLotExits(price float64, token string) {
+	if len(b.Intents) > 0 {
+		b.reconcileIntents(token) // may book sells and shift the records
+	}
+
+	var lots []int
+	var qty float64
+	for i, r := range b.Records {
+		if r.AmountBought <= 0 || b.dir()*(price-b.lotTarget(r)) < 0 {
+			continue
+		}
+		// A lot worth less than the exchange minimum can't be sold on its own
+		if b.instrument != nil && r.AmountBought*price < b.instrument.MinOrderAmt {
+			continue
+		}
+		lots = append(lots, i)
+		qty += r.AmountBought
+	}
+	if len(lots) == 0 {
+		return
+	}
+
+	sold := make([]DCARecord, len(lots))
+	for n, i := range lots {
+		sold[n] = b.Records[i]
+	}
+	fmt.Printf("LOT SELL → %d lot(s), %.6f at %.4f\n", len(lots), qty, price)
+
+	// A lot exit sells known lots, so it is matched to them whatever the
+	// cost-basis method. Split pieces book later, after emptied records
+	// were dropped, so the lots are looked up again each time.
+	var filledQty, filledValue, realizedPNL float64
+	book := func(price, qty float64) float64 {
+		pnl := b.bookRecords(price, qty, b.lotIndexes(sold), "lot")
+		filledQty += qty
+		filledValue += price * qty
+		realizedPNL += pnl
+		return pnl
+	}
+	b.sellAtMarket(price, qty, true, book, func() {
+		b.lotsSold(sold, filledQty, filledValue/filledQty, realizedPNL, token)
+	}, token)
+}
+
+// lotsSold books the lot exit once all of it has filled
+func (b *DCABot) lotsSold(sold []DCARecord, filledQty, fillPrice, realizedPNL float64, token string) {
+	b.LotSells += len(sold)
+	b.LotPNL += realizedPNL
+	if b.Deal != nil {
+		b.Deal.LotSells += len(sold)
+	}
+	b.dropLotDust(sold)
+	b.saveState()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🧺 %s LOT EXIT\nSymbol: %s\nPrice: %.4f\nQty: %.6f\n", strings.ToUpper(b.Exchange.Name()), b.Symbol, fillPrice, filledQty)
+	for _, r := range sold {
+		pnl := b.dir() * (fillPrice - r.Price) * r.AmountBought
+		fmt.Fprintf(&sb, "Buy #%d @ %.4f: %.2f USDT (%.2f%%)\n", r.BuyNumber, r.Price, pnl, pnl/(r.Price*r.AmountBought)*100)
+	}
+	fmt.Fprintf(&sb, "Realized: %.2f USDT\nLots left: %d\nUnder water: %.2f USDT", realizedPNL, len(b.Records), b.unrealizedLots(fillPrice))
+	sendTelegramMessage(token, sb.String())
+}
+
+// lotIndexes finds the sold lots among the current records
+func (b *DCABot) lotIndexes(sold []DCARecord) []int {
+	var lots []int
+	for i, r := range b.Records {
+		for _, s := range sold {
+			if r.BuyNumber == s.BuyNumber && r.Price == s.Price && r.USDTSpent == s.USDTSpent {
+				lots = append(lots, i)
+				break
+			}
+		}
+	}
+	return lots
+}
+
+// dropLotDust removes what the lot step left behind of sold lots, it can't be
+// sold on its own anyway
+func (b *DCABot) dropLotDust(sold []DCARecord) {
+	if b.instrument == nil {
+		return
+	}
+	var kept []DCARecord
+	for _, r := range b.Records {
+		dust := r.AmountBought < b.instrument.QtyStep
+		for _, s := range sold {
+			if dust && s.BuyNumber == r.BuyNumber && s.Price == r.Price {
+				r.AmountBought = 0
+			}
+		}
+		if r.AmountBought > 0 {
+			kept = append(kept, r)
+		}
+	}
+	b.Records = kept
+}
+
+// unrealizedLots is the open PNL of the lots still held
+func (b *DCABot) unrealizedLots(price float64) float64 {
+	pnl := 0.0
+	for _, r := range b.Records {
+		pnl += b.dir() * (price - r.Price) * r.AmountBought
+	}
+	return pnl
+}
+
+// lotStatus is the lot exit line for /status and the daily report
+func (b *DCABot) lotStatus() string {
+	if b.ExitMode != ExitLot {
+		return ""
+	}
+	status := fmt.Sprintf("Lot exits: %d sold, %.2f USDT realized", b.LotSells, b.LotPNL)
+	if len(b.Records) > 0 {
+		next := 0.0
+		for _, r := range b.Records {
+			if t := b.lotTarget(r); next == 0 || b.dir()*(t-next) < 0 {
+				next = t
+			}
+		}
+		status += fmt.Sprintf("\nOpen lots: %d, next target %.4f", len(b.Records), next)
+	}
+	return status
+}
@@ -0,0 +1,589 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const okxOrderNotFound = 51603
+
+// OKX trades OKX spot and serves its candles. Every host comes from the
+// environment, so it can be pointed at a local mock server.
+type OKX struct {
+	env        Environment
+	apiKey     string
+	secret     string
+	passphrase string
+	clock      *ClockSync
+	limiter    *RateLimiter
+}
+
+func NewOKX(env Environment, apiKey, secret, passphrase string) *OKX {
+	e := &OKX{
+		env:        env,
+		apiKey:     apiKey,
+		secret:     secret,
+		passphrase: passphrase,
+		limiter:    rateLimiterFor("okx", apiKey),
+	}
+	e.clock = NewClockSync("okx", e.serverTime)
+	if err := e.clock.Sync(); err != nil {
+		log.Printf("OKX clock sync error: %v", err)
+	}
+	go e.clock.Run()
+	return e
+}
+
+func (e *OKX) Name() string {
+	return "okx"
+}
+
+// okxInstID turns BTCUSDT into OKX's BTC-USDT
+func okxInstID(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if strings.Contains(symbol, "-") {
+		return symbol
+	}
+	for _, quote := range []string{"USDT", "USDC", "BTC", "ETH", "EUR"} {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return symbol[:len(symbol)-len(quote)] + "-" + quote
+		}
+	}
+	return symbol
+}
+
+// okxClOrdID maps a link id onto the letters and digits OKX accepts
+func okxClOrdID(linkID string) string {
+	return strings.ReplaceAll(linkID, "-", "x")
+}
+
+// okxBar maps Binance-style intervals to OKX bars, which use upper case
+// from hours up
+func okxBar(interval string) string {
+	if strings.HasSuffix(interval, "m") {
+		return interval
+	}
+	return strings.ToUpper(interval)
+}
+
+func (e *OKX) sign(prehash string) string {
+	mac := hmac.New(sha256.New, []byte(e.secret))
+	mac.Write([]byte(prehash))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// do sends a REST call and decodes the data array into v. Lost GETs come
+// back as network errors so callers can retry them; a lost POST is returned
+// as is because it may have gone through.
+func (e *OKX) do(method, path string, query url.Values, payload any, signed bool, p Priority, v any) error {
+	requestPath := path
+	if len(query) > 0 {
+		requestPath += "?" + query.Encode()
+	}
+
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+
+	if err := e.limiter.Wait(p, 1, path); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, e.env.OKXREST+requestPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.env.OKXSimulated {
+		req.Header.Set("x-simulated-trading", "1")
+	}
+	if signed {
+		timestamp := e.clock.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+		req.Header.Set("OK-ACCESS-KEY", e.apiKey)
+		req.Header.Set("OK-ACCESS-PASSPHRASE", e.passphrase)
+		req.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+		req.Header.Set("OK-ACCESS-SIGN", e.sign(timestamp+method+requestPath+string(body)))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if method == http.MethodGet {
+			return &ExchangeError{Exchange: "okx", Kind: ErrNetwork, Err: err}
+		}
+		return fmt.Errorf("okx %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &ExchangeError{Exchange: "okx", Kind: ErrNetwork, Err: err}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		e.limiter.BlockUntil(path, time.Now().Add(2*time.Second)) // OKX limits per endpoint
+	}
+	if err := okxError(resp.StatusCode, raw); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return err
+	}
+	return json.Unmarshal(envelope.Data, v)
+}
+
+func (e *OKX) serverTime() (time.Time, error) {
+	var data []struct {
+		Ts string `json:"ts"`
+	}
+	if err := e.do(http.MethodGet, "/api/v5/public/time", nil, nil, false, PriorityMarketData, &data); err != nil {
+		return time.Time{}, err
+	}
+	if len(data) == 0 {
+		return time.Time{}, fmt.Errorf("okx server time missing")
+	}
+	ms, err := strconv.ParseInt(data[0].Ts, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+////////////////////////////////////////////////////////////
+// Instruments & Orders
+////////////////////////////////////////////////////////////
+
+func (e *OKX) Instrument(symbol string) (*Instrument, error) {
+	query := url.Values{}
+	query.Set("instType", "SPOT")
+	query.Set("instId", okxInstID(symbol))
+
+	var data []struct {
+		TickSz string `json:"tickSz"`
+		LotSz  string `json:"lotSz"`
+		MinSz  string `json:"minSz"`
+	}
+	err := withRetry("OKX instrument", func() error {
+		return e.do(http.MethodGet, "/api/v5/public/instruments", query, nil, false, PriorityMarketData, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("instrument %s not found", symbol)
+	}
+
+	// OKX spot has no minimum notional, only a minimum size in the base coin
+	minQty := parseStringToFloat(data[0].MinSz)
+	last, err := e.lastPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Instrument{
+		TickSize:    parseStringToFloat(data[0].TickSz),
+		QtyStep:     parseStringToFloat(data[0].LotSz),
+		MinOrderQty: minQty,
+		MinOrderAmt: minQty * last,
+	}, nil
+}
+
+func (e *OKX) lastPrice(symbol string) (float64, error) {
+	query := url.Values{}
+	query.Set("instId", okxInstID(symbol))
+
+	var data []struct {
+		Last string `json:"last"`
+	}
+	err := withRetry("OKX ticker", func() error {
+		return e.do(http.MethodGet, "/api/v5/market/ticker", query, nil, false, PriorityMarketData, &data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, fmt.Errorf("ticker %s not found", symbol)
+	}
+	return parseStringToFloat(data[0].Last), nil
+}
+
+type okxOrder struct {
+	OrdID     string `json:"ordId"`
+	ClOrdID   string `json:"clOrdId"`
+	State     string `json:"state"`
+	Px        string `json:"px"`
+	Sz        string `json:"sz"`
+	AccFillSz string `json:"accFillSz"`
+	AvgPx     string `json:"avgPx"`
+	CTime     string `json:"cTime"`
+}
+
+func (o okxOrder) toOrder() *Order {
+	status := OrderNew
+	switch o.State {
+	case "partially_filled":
+		status = OrderPartiallyFilled
+	case "filled":
+		status = OrderFilled
+	case "canceled", "mmp_canceled":
+		status = OrderCancelled
+	}
+	created, _ := strconv.ParseInt(o.CTime, 10, 64)
+
+	order := &Order{
+		ID:        o.OrdID,
+		LinkID:    o.ClOrdID,
+		Status:    status,
+		Price:     parseStringToFloat(o.Px),
+		Qty:       parseStringToFloat(o.Sz),
+		FilledQty: parseStringToFloat(o.AccFillSz),
+		AvgPrice:  parseStringToFloat(o.AvgPx),
+		Created:   time.UnixMilli(created),
+	}
+	order.FilledValue = order.FilledQty * order.AvgPrice
+	return order
+}
+
+// PlaceOrder sends a single attempt. The response only carries the ids, so
+// fills are read back through GetOrder.
+func (e *OKX) PlaceOrder(req OrderRequest) (*Order, error) {
+	payload := map[string]string{
+		"instId":  okxInstID(req.Symbol),
+		"tdMode":  "cash",
+		"side":    strings.ToLower(req.Side),
+		"ordType": strings.ToLower(req.Type),
+		"sz":      req.Qty,
+		"clOrdId": okxClOrdID(req.LinkID),
+	}
+	if req.QuoteQty != "" {
+		payload["sz"] = req.QuoteQty
+		payload["tgtCcy"] = "quote_ccy"
+	}
+	if req.Type == "Limit" {
+		payload["px"] = req.Price
+		// OKX has no time in force, it is part of the order type
+		switch req.TimeInForce {
+		case "IOC":
+			payload["ordType"] = "ioc"
+		case "FOK":
+			payload["ordType"] = "fok"
+		case "PostOnly":
+			payload["ordType"] = "post_only"
+		}
+	}
+
+	var data []okxOrder
+	if err := e.do(http.MethodPost, "/api/v5/trade/order", nil, payload, true, PriorityOrder, &data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("okx order response is empty")
+	}
+	order := data[0].toOrder()
+	order.Created = time.Now()
+	return order, nil
+}
+
+func (e *OKX) AmendOrder(symbol, orderID, qty, price string) error {
+	payload := map[string]string{
+		"instId": okxInstID(symbol),
+		"ordId":  orderID,
+		"newSz":  qty,
+		"newPx":  price,
+	}
+	return withRetry("OKX amend", func() error {
+		return e.do(http.MethodPost, "/api/v5/trade/amend-order", nil, payload, true, PriorityOrder, nil)
+	})
+}
+
+func (e *OKX) CancelOrder(symbol, orderID string) error {
+	payload := map[string]string{
+		"instId": okxInstID(symbol),
+		"ordId":  orderID,
+	}
+	return withRetry("OKX cancel", func() error {
+		return e.do(http.MethodPost, "/api/v5/trade/cancel-order", nil, payload, true, PriorityOrder, nil)
+	})
+}
+
+func (e *OKX) GetOrder(symbol, orderID string) (*Order, error) {
+	return e.fetchOrder(symbol, "ordId", orderID)
+}
+
+func (e *OKX) GetOrderByLinkID(symbol, linkID string) (*Order, error) {
+	return e.fetchOrder(symbol, "clOrdId", okxClOrdID(linkID))
+}
+
+func (e *OKX) fetchOrder(symbol, idKey, id string) (*Order, error) {
+	query := url.Values{}
+	query.Set("instId", okxInstID(symbol))
+	query.Set(idKey, id)
+
+	var data []okxOrder
+	err := withRetry("OKX order", func() error {
+		return e.do(http.MethodGet, "/api/v5/trade/order", query, nil, true, PriorityMarketData, &data)
+	})
+	if exErr, ok := asExchangeError(err); ok && exErr.Code == okxOrderNotFound {
+		return nil, errOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errOrderNotFound
+	}
+	return data[0].toOrder(), nil
+}
+
+////////////////////////////////////////////////////////////
+// Signal Orders
+////////////////////////////////////////////////////////////
+
+// PlaceSignalOrder opens or closes a signal bot position on the USDT
+// perpetual at market. qty is in coins, OKX counts contracts of ctVal coins.
+func (e *OKX) PlaceSignalOrder(symbol, side string, qty float64) error {
+	instID := okxInstID(symbol) + "-SWAP"
+	query := url.Values{}
+	query.Set("instType", "SWAP")
+	query.Set("instId", instID)
+
+	var data []struct {
+		CtVal string `json:"ctVal"`
+		LotSz string `json:"lotSz"`
+	}
+	err := withRetry("OKX swap instrument", func() error {
+		return e.do(http.MethodGet, "/api/v5/public/instruments", query, nil, false, PriorityMarketData, &data)
+	})
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 || parseStringToFloat(data[0].CtVal) == 0 {
+		return fmt.Errorf("instrument %s not found", instID)
+	}
+
+	contracts := floorToStep(qty/parseStringToFloat(data[0].CtVal), parseStringToFloat(data[0].LotSz))
+	if parseStringToFloat(contracts) == 0 {
+		return fmt.Errorf("%g %s is less than one %s contract", qty, symbol, instID)
+	}
+	payload := map[string]string{
+		"instId":  instID,
+		"tdMode":  "cross",
+		"side":    strings.ToLower(side),
+		"ordType": "market",
+		"sz":      contracts,
+	}
+
+	// Sent once, a lost response may still have opened the position
+	var placed []okxOrder
+	if err := e.do(http.MethodPost, "/api/v5/trade/order", nil, payload, true, PriorityOrder, &placed); err != nil {
+		return err
+	}
+	log.Printf("OKX %s %s %s contracts placed", instID, side, contracts)
+	return nil
+}
+
+////////////////////////////////////////////////////////////
+// Candles
+////////////////////////////////////////////////////////////
+
+// okxCandle parses [ts, o, h, l, c, vol, volCcy, volCcyQuote, confirm]
+func okxCandle(row []string, interval string) (Candle, bool) {
+	if len(row) < 9 {
+		return Candle{}, false
+	}
+	ts, _ := strconv.ParseInt(row[0], 10, 64)
+	return Candle{
+		Open:      parseStringToFloat(row[1]),
+		High:      parseStringToFloat(row[2]),
+		Low:       parseStringToFloat(row[3]),
+		Close:     parseStringToFloat(row[4]),
+		Volume:    parseStringToFloat(row[5]),
+		CloseTime: time.UnixMilli(ts).Add(intervalDuration(interval)),
+		IsFinal:   row[8] == "1",
+	}, true
+}
+
+func (e *OKX) Candles(symbol, interval string, limit int) ([]Candle, error) {
+	query := url.Values{}
+	query.Set("instId", okxInstID(symbol))
+	query.Set("bar", okxBar(interval))
+	query.Set("limit", strconv.Itoa(min(limit, 300)))
+
+	var data [][]string
+	err := withRetry("OKX candles", func() error {
+		return e.do(http.MethodGet, "/api/v5/market/candles", query, nil, false, PriorityMarketData, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// OKX lists newest first and includes the candle still forming
+	var candles []Candle
+	for i := len(data) - 1; i >= 0; i-- {
+		if c, ok := okxCandle(data[i], interval); ok && c.IsFinal {
+			candles = append(candles, c)
+		}
+	}
+	return candles, nil
+}
+
+func (e *OKX) StreamCandles(symbol, interval string, onCandle func(Candle)) error {
+	arg := map[string]string{"channel": "candle" + okxBar(interval), "instId": okxInstID(symbol)}
+	return e.stream(e.env.OKXBusinessWS, false, arg, func(data json.RawMessage) {
+		var rows [][]string
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return
+		}
+		for _, row := range rows {
+			if c, ok := okxCandle(row, interval); ok && c.IsFinal {
+				onCandle(c)
+			}
+		}
+	})
+}
+
+////////////////////////////////////////////////////////////
+// WebSocket Streams
+////////////////////////////////////////////////////////////
+
+func (e *OKX) StreamTrades(symbol string, onPrice func(price float64)) error {
+	arg := map[string]string{"channel": "trades", "instId": okxInstID(symbol)}
+	return e.stream(e.env.OKXPublicWS, false, arg, func(data json.RawMessage) {
+		var trades []struct {
+			Px string `json:"px"`
+		}
+		if err := json.Unmarshal(data, &trades); err != nil || len(trades) == 0 {
+			return
+		}
+		onPrice(parseStringToFloat(trades[0].Px))
+	})
+}
+
+// StreamOrders pushes every update to our spot orders, so a take profit fill
+// is noticed without waiting for the next poll
+func (e *OKX) StreamOrders(onOrder func(*Order)) error {
+	arg := map[string]string{"channel": "orders", "instType": "SPOT"}
+	return e.stream(e.env.OKXPrivateWS, true, arg, func(data json.RawMessage) {
+		var orders []okxOrder
+		if err := json.Unmarshal(data, &orders); err != nil {
+			return
+		}
+		for _, o := range orders {
+			onOrder(o.toOrder())
+		}
+	})
+}
+
+// stream subscribes to one channel, logging in first for private ones, and
+// hands each data array to onData until the connection drops
+func (e *OKX) stream(wsURL string, private bool, arg map[string]string, onData func(json.RawMessage)) error {
+	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// The ping goroutine and the login reply both write, gorilla allows
+	// only one writer at a time
+	var writeMu sync.Mutex
+	writeJSON := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return c.WriteJSON(v)
+	}
+
+	if private {
+		timestamp := strconv.FormatInt(e.clock.Now().Unix(), 10)
+		login := map[string]interface{}{
+			"op": "login",
+			"args": []map[string]string{{
+				"apiKey":     e.apiKey,
+				"passphrase": e.passphrase,
+				"timestamp":  timestamp,
+				"sign":       e.sign(timestamp + "GET/users/self/verify"),
+			}},
+		}
+		if err := writeJSON(login); err != nil {
+			return err
+		}
+	} else if err := writeJSON(map[string]interface{}{"op": "subscribe", "args": []map[string]string{arg}}); err != nil {
+		return err
+	}
+
+	// OKX drops connections that stay quiet for 30s
+	go func() {
+		ticker := time.NewTicker(20 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			writeMu.Lock()
+			err := c.WriteMessage(websocket.TextMessage, []byte("ping"))
+			writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("✅ OKX WS Connected for %s\n", arg["channel"])
+
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if string(message) == "pong" {
+			continue
+		}
+
+		var msg struct {
+			Event string          `json:"event"`
+			Code  string          `json:"code"`
+			Msg   string          `json:"msg"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Event {
+		case "error":
+			code, _ := strconv.Atoi(msg.Code)
+			return &ExchangeError{Exchange: "okx", Kind: okxErrorKind(0, code, msg.Msg), Code: code, Message: msg.Msg}
+		case "login":
+			if err := writeJSON(map[string]interface{}{"op": "subscribe", "args": []map[string]string{arg}}); err != nil {
+				return err
+			}
+			continue
+		case "":
+		default:
+			continue
+		}
+
+		if len(msg.Data) > 0 {
+			onData(msg.Data)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	okxTestKey        = "test-key"
+	okxTestSecret     = "test-secret"
+	okxTestPassphrase = "test-passphrase"
+)
+
+func okxFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "okx", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// okxMock is a local OKX: REST routes answer with fixtures, signed calls are
+// verified the way OKX does, and the bodies sent are kept for the test
+type okxMock struct {
+	t      *testing.T
+	server *httptest.Server
+	mux    *http.ServeMux
+
+	mu     sync.Mutex
+	bodies map[string][]map[string]string
+}
+
+func newOKXMock(t *testing.T) *okxMock {
+	m := &okxMock{t: t, mux: http.NewServeMux(), bodies: map[string][]map[string]string{}}
+	m.server = httptest.NewServer(m.mux)
+	t.Cleanup(m.server.Close)
+	m.route("/api/v5/public/time", false, func(r *http.Request) []byte {
+		return []byte(fmt.Sprintf(`{"code":"0","msg":"","data":[{"ts":"%d"}]}`, time.Now().UnixMilli()))
+	})
+	return m
+}
+
+// route serves path, checking the signature first when signed
+func (m *okxMock) route(path string, signed bool, reply func(r *http.Request) []byte) {
+	m.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if signed {
+			if r.Header.Get("OK-ACCESS-KEY") != okxTestKey || r.Header.Get("OK-ACCESS-PASSPHRASE") != okxTestPassphrase {
+				m.t.Errorf("%s: missing or wrong key headers", path)
+			}
+			timestamp := r.Header.Get("OK-ACCESS-TIMESTAMP")
+			if _, err := time.Parse("2006-01-02T15:04:05.000Z", timestamp); err != nil {
+				m.t.Errorf("%s: timestamp %q is not ISO with milliseconds", path, timestamp)
+			}
+			if got, want := r.Header.Get("OK-ACCESS-SIGN"), okxTestSign(timestamp+r.Method+r.URL.RequestURI()+string(body)); got != want {
+				m.t.Errorf("%s: signature %s, want %s", path, got, want)
+			}
+		}
+		if len(body) > 0 {
+			var payload map[string]string
+			if err := json.Unmarshal(body, &payload); err != nil {
+				m.t.Errorf("%s: body is not a JSON object: %s", path, body)
+			}
+			m.mu.Lock()
+			m.bodies[path] = append(m.bodies[path], payload)
+			m.mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply(r))
+	})
+}
+
+func (m *okxMock) sent(path string) []map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bodies[path]
+}
+
+func (m *okxMock) env() Environment {
+	ws := "ws" + strings.TrimPrefix(m.server.URL, "http")
+	return Environment{
+		Name:          "mock",
+		OKXREST:       m.server.URL,
+		OKXPublicWS:   ws + "/ws/v5/public",
+		OKXPrivateWS:  ws + "/ws/v5/private",
+		OKXBusinessWS: ws + "/ws/v5/business",
+		OKXSimulated:  true,
+	}
+}
+
+func (m *okxMock) client() *OKX {
+	return NewOKX(m.env(), okxTestKey, okxTestSecret, okxTestPassphrase)
+}
+
+// okxTestSign is OKX's signature written out independently of OKX.sign
+func okxTestSign(prehash string) string {
+	mac := hmac.New(sha256.New, []byte(okxTestSecret))
+	mac.Write([]byte(prehash))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestOKXSign(t *testing.T) {
+	e := &OKX{secret: okxTestSecret}
+	prehash := "2024-10-19T08:00:00.000ZGET/api/v5/trade/order?instId=BTC-USDT&ordId=1"
+	if got := e.sign(prehash); got != okxTestSign(prehash) {
+		t.Fatalf("sign = %s, want %s", got, okxTestSign(prehash))
+	}
+}
+
+func TestOKXSimulatedHeader(t *testing.T) {
+	m := newOKXMock(t)
+	var simulated string
+	m.route("/api/v5/market/ticker", false, func(r *http.Request) []byte {
+		simulated = r.Header.Get("x-simulated-trading")
+		return okxFixture(t, "ticker.json")
+	})
+	if _, err := m.client().lastPrice("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if simulated != "1" {
+		t.Errorf("demo environment sent x-simulated-trading %q", simulated)
+	}
+}
+
+func TestOKXInstrument(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/public/instruments", false, func(r *http.Request) []byte {
+		if r.URL.Query().Get("instId") != "BTC-USDT" || r.URL.Query().Get("instType") != "SPOT" {
+			t.Errorf("instrument query %s", r.URL.RawQuery)
+		}
+		return okxFixture(t, "instrument_spot.json")
+	})
+	m.route("/api/v5/market/ticker", false, func(r *http.Request) []byte { return okxFixture(t, "ticker.json") })
+
+	inst, err := m.client().Instrument("BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.TickSize != 0.1 || inst.QtyStep != 0.00000001 || inst.MinOrderQty != 0.00001 {
+		t.Errorf("instrument %+v", inst)
+	}
+	if want := 0.00001 * 65000.1; math.Abs(inst.MinOrderAmt-want) > 1e-9 {
+		t.Errorf("MinOrderAmt = %g, want %g", inst.MinOrderAmt, want)
+	}
+}
+
+func TestOKXPlaceOrder(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/trade/order", true, func(r *http.Request) []byte { return okxFixture(t, "order_placed.json") })
+
+	order, err := m.client().PlaceOrder(OrderRequest{
+		Symbol: "BTCUSDT", Side: "Buy", Type: "Market", QuoteQty: "50.00", LinkID: "BTCUSDT-1p5-1-B1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != "1881235789412345856" {
+		t.Errorf("order id %s", order.ID)
+	}
+
+	sent := m.sent("/api/v5/trade/order")
+	if len(sent) != 1 {
+		t.Fatalf("%d orders sent", len(sent))
+	}
+	want := map[string]string{
+		"instId": "BTC-USDT", "tdMode": "cash", "side": "buy", "ordType": "market",
+		"sz": "50.00", "tgtCcy": "quote_ccy", "clOrdId": "BTCUSDTx1p5x1xB1",
+	}
+	for k, v := range want {
+		if sent[0][k] != v {
+			t.Errorf("%s = %q, want %q", k, sent[0][k], v)
+		}
+	}
+}
+
+func TestOKXPlaceOrderIOC(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/trade/order", true, func(r *http.Request) []byte { return okxFixture(t, "order_placed.json") })
+
+	_, err := m.client().PlaceOrder(OrderRequest{
+		Symbol: "BTCUSDT", Side: "Sell", Type: "Limit", Qty: "0.01", Price: "64000", TimeInForce: "IOC", LinkID: "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := m.sent("/api/v5/trade/order"); sent[0]["ordType"] != "ioc" || sent[0]["px"] != "64000" {
+		t.Errorf("IOC limit sent as %v", sent[0])
+	}
+}
+
+func TestOKXPlaceOrderRejected(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/trade/order", true, func(r *http.Request) []byte { return okxFixture(t, "order_rejected.json") })
+
+	_, err := m.client().PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "Buy", Type: "Market", QuoteQty: "50.00", LinkID: "x"})
+	exErr, ok := asExchangeError(err)
+	if !ok {
+		t.Fatalf("got %v, want an exchange error", err)
+	}
+	if exErr.Kind != ErrInsufficientBalance || exErr.Code != 51008 || !exErr.Answered() {
+		t.Errorf("got %+v, want an answered insufficient balance from sCode 51008", exErr)
+	}
+}
+
+func TestOKXGetOrder(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/trade/order", true, func(r *http.Request) []byte {
+		if r.URL.Query().Get("clOrdId") == "missing" {
+			return okxFixture(t, "order_not_found.json")
+		}
+		return okxFixture(t, "order_filled.json")
+	})
+	e := m.client()
+
+	order, err := e.GetOrderByLinkID("BTCUSDT", "BTCUSDT-1p5-1-B1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != OrderFilled || order.FilledQty != 0.00076923 || order.AvgPrice != 65001.3 {
+		t.Errorf("order %+v", order)
+	}
+	if want := 0.00076923 * 65001.3; math.Abs(order.FilledValue-want) > 1e-9 {
+		t.Errorf("FilledValue = %g, want %g", order.FilledValue, want)
+	}
+
+	if _, err := e.GetOrderByLinkID("BTCUSDT", "missing"); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("missing order: got %v, want errOrderNotFound", err)
+	}
+}
+
+func TestOKXCandles(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/market/candles", false, func(r *http.Request) []byte {
+		if r.URL.Query().Get("bar") != "1H" {
+			t.Errorf("bar %s, want 1H", r.URL.Query().Get("bar"))
+		}
+		return okxFixture(t, "candles.json")
+	})
+
+	candles, err := m.client().Candles("BTCUSDT", "1h", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("%d candles, the forming one must be dropped", len(candles))
+	}
+	if candles[0].Close != 64900 || candles[1].Close != 65010.1 {
+		t.Errorf("candles not oldest first: %+v", candles)
+	}
+	if want := time.UnixMilli(1729328400000).Add(time.Hour); !candles[1].CloseTime.Equal(want) {
+		t.Errorf("close time %v, want %v", candles[1].CloseTime, want)
+	}
+}
+
+func TestOKXSignalOrder(t *testing.T) {
+	m := newOKXMock(t)
+	m.route("/api/v5/public/instruments", false, func(r *http.Request) []byte {
+		if r.URL.Query().Get("instId") != "BTC-USDT-SWAP" {
+			t.Errorf("swap instrument query %s", r.URL.RawQuery)
+		}
+		return okxFixture(t, "instrument_swap.json")
+	})
+	m.route("/api/v5/trade/order", true, func(r *http.Request) []byte { return okxFixture(t, "order_placed.json") })
+
+	if err := m.client().PlaceSignalOrder("btcusdt", "SELL", 0.057); err != nil {
+		t.Fatal(err)
+	}
+	sent := m.sent("/api/v5/trade/order")
+	if len(sent) != 1 {
+		t.Fatalf("%d orders sent", len(sent))
+	}
+	if sent[0]["instId"] != "BTC-USDT-SWAP" || sent[0]["side"] != "sell" || sent[0]["tdMode"] != "cross" || sent[0]["sz"] != "5.70" {
+		t.Errorf("signal order %v, want 5.70 BTC-USDT-SWAP contracts sold", sent[0])
+	}
+}
+
+// okxWS serves one WebSocket path, handing each connection to script
+func (m *okxMock) ws(path string, script func(c *websocket.Conn)) {
+	upgrader := websocket.Upgrader{}
+	m.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			m.t.Error(err)
+			return
+		}
+		defer c.Close()
+		script(c)
+	})
+}
+
+// expectOp reads messages until one with op arrives, skipping pings
+func expectOp(t *testing.T, c *websocket.Conn, op string) map[string]string {
+	t.Helper()
+	for {
+		_, raw, err := c.ReadMessage()
+		if err != nil {
+			t.Errorf("waiting for %s: %v", op, err)
+			return nil
+		}
+		if string(raw) == "ping" {
+			continue
+		}
+		var msg struct {
+			Op   string              `json:"op"`
+			Args []map[string]string `json:"args"`
+		}
+		if err := json.Unmarshal(raw, &msg); err != nil || msg.Op != op || len(msg.Args) != 1 {
+			t.Errorf("got %s, want op %s with one arg", raw, op)
+			return nil
+		}
+		return msg.Args[0]
+	}
+}
+
+func TestOKXStreamTrades(t *testing.T) {
+	m := newOKXMock(t)
+	m.ws("/ws/v5/public", func(c *websocket.Conn) {
+		arg := expectOp(t, c, "subscribe")
+		if arg["channel"] != "trades" || arg["instId"] != "BTC-USDT" {
+			t.Errorf("subscribed to %v", arg)
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscribe","arg":{"channel":"trades","instId":"BTC-USDT"},"connId":"a4d3ae55"}`))
+		c.WriteMessage(websocket.TextMessage, okxFixture(t, "ws_trades.json"))
+	})
+
+	var prices []float64
+	err := m.client().StreamTrades("BTCUSDT", func(price float64) { prices = append(prices, price) })
+	if err == nil {
+		t.Fatal("stream returned without an error after the server closed")
+	}
+	if len(prices) != 1 || prices[0] != 65001.5 {
+		t.Errorf("prices %v, want [65001.5]", prices)
+	}
+}
+
+func TestOKXStreamOrders(t *testing.T) {
+	m := newOKXMock(t)
+	m.ws("/ws/v5/private", func(c *websocket.Conn) {
+		login := expectOp(t, c, "login")
+		if login["apiKey"] != okxTestKey || login["passphrase"] != okxTestPassphrase {
+			t.Errorf("login %v", login)
+		}
+		if want := okxTestSign(login["timestamp"] + "GET/users/self/verify"); login["sign"] != want {
+			t.Errorf("login sign %s, want %s", login["sign"], want)
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"event":"login","code":"0","msg":"","connId":"a4d3ae55"}`))
+
+		arg := expectOp(t, c, "subscribe")
+		if arg["channel"] != "orders" || arg["instType"] != "SPOT" {
+			t.Errorf("subscribed to %v", arg)
+		}
+		c.WriteMessage(websocket.TextMessage, okxFixture(t, "ws_orders.json"))
+	})
+
+	var orders []*Order
+	m.client().StreamOrders(func(o *Order) { orders = append(orders, o) })
+	if len(orders) != 1 {
+		t.Fatalf("%d order updates, want 1", len(orders))
+	}
+	if o := orders[0]; o.Status != OrderFilled || o.LinkID != "BTCUSDTx1p5x1xT3" || o.FilledQty != 0.0015 || o.AvgPrice != 66000 {
+		t.Errorf("order %+v", o)
+	}
+}
+
+func TestOKXStreamLoginError(t *testing.T) {
+	m := newOKXMock(t)
+	m.ws("/ws/v5/private", func(c *websocket.Conn) {
+		expectOp(t, c, "login")
+		c.WriteMessage(websocket.TextMessage, []byte(`{"event":"error","code":"60009","msg":"Login failed.","connId":"a4d3ae55"}`))
+		c.ReadMessage() // hold the connection until the client hangs up
+	})
+
+	err := m.client().StreamOrders(func(*Order) {})
+	if exErr, ok := asExchangeError(err); !ok || exErr.Code != 60009 {
+		t.Errorf("got %v, want the login error", err)
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1m":  time.Minute,
+		"15m": 15 * time.Minute,
+		"4h":  4 * time.Hour,
+		"1H":  time.Hour,
+		"1d":  24 * time.Hour,
+		"1w":  7 * 24 * time.Hour,
+		"1M":  30 * 24 * time.Hour,
+		"x":   0,
+	}
+	for interval, want := range cases {
+		if got := intervalDuration(interval); got != want {
+			t.Errorf("intervalDuration(%q) = %v, want %v", interval, got, want)
+		}
+	}
+}
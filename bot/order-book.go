@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Order Book
+////////////////////////////////////////////////////////////
+
+// OrderBook is a live price level book fed by an OrderBookFeed. It is written
+// by the feed goroutine and read before orders, hence the lock.
+type OrderBook struct {
+	mu      sync.Mutex
+	bids    map[float64]float64
+	asks    map[float64]float64
+	updated time.Time
+}
+
+type bookLevel struct {
+	Price float64
+	Size  float64
+}
+
+// BookEstimate is what a market order of a given size would do to the book
+type BookEstimate struct {
+	Best     float64 // best price on the side the order takes from
+	Spread   float64 // percent of the mid price
+	AvgPrice float64
+	Slippage float64 // percent from Best to AvgPrice, against the order
+	Complete bool    // false when the visible book can't fill the order
+}
+
+func NewOrderBook() *OrderBook {
+	return &OrderBook{bids: map[float64]float64{}, asks: map[float64]float64{}}
+}
+
+// apply replaces the book with a snapshot or merges a delta, a size of 0
+// removes the level
+func (ob *OrderBook) apply(snapshot bool, bids, asks [][2]string) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if snapshot {
+		ob.bids = map[float64]float64{}
+		ob.asks = map[float64]float64{}
+	}
+	merge := func(side map[float64]float64, levels [][2]string) {
+		for _, l := range levels {
+			price, size := parseStringToFloat(l[0]), parseStringToFloat(l[1])
+			if size == 0 {
+				delete(side, price)
+			} else {
+				side[price] = size
+			}
+		}
+	}
+	merge(ob.bids, bids)
+	merge(ob.asks, asks)
+	ob.updated = time.Now()
+}
+
+// Updated is when the last update arrived
+func (ob *OrderBook) Updated() time.Time {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	return ob.updated
+}
+
+// levels returns one side sorted best first
+func (ob *OrderBook) levels(side map[float64]float64, ascending bool) []bookLevel {
+	levels := make([]bookLevel, 0, len(side))
+	for price, size := range side {
+		levels = append(levels, bookLevel{price, size})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if ascending {
+			return levels[i].Price < levels[j].Price
+		}
+		return levels[i].Price > levels[j].Price
+	})
+	return levels
+}
+
+// Estimate walks the book for a market order on side, sized in base coin by
+// qty or, when qty is 0, in quote coin by notional
+func (ob *OrderBook) Estimate(side string, qty, notional float64) (BookEstimate, bool) {
+	ob.mu.Lock()
+	bids := ob.levels(ob.bids, false)
+	asks := ob.levels(ob.asks, true)
+	ob.mu.Unlock()
+
+	if len(bids) == 0 || len(asks) == 0 {
+		return BookEstimate{}, false
+	}
+	mid := (bids[0].Price + asks[0].Price) / 2
+	est := BookEstimate{Spread: (asks[0].Price - bids[0].Price) / mid * 100}
+
+	levels := asks
+	if side == "Sell" {
+		levels = bids
+	}
+	est.Best = levels[0].Price
+
+	var filledQty, filledValue float64
+	for _, l := range levels {
+		take := l.Size
+		if qty > 0 {
+			take = math.Min(take, qty-filledQty)
+		} else {
+			take = math.Min(take, (notional-filledValue)/l.Price)
+		}
+		filledQty += take
+		filledValue += take * l.Price
+		if (qty > 0 && filledQty >= qty) || (qty == 0 && filledValue >= notional*0.999999) {
+			est.Complete = true
+			break
+		}
+	}
+	if filledQty == 0 {
+		return est, false
+	}
+	est.AvgPrice = filledValue / filledQty
+	est.Slippage = math.Abs(est.AvgPrice-est.Best) / est.Best * 100
+	return est, true
+}
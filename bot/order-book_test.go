@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestOrderBookApply(t *testing.T) {
+	ob := NewOrderBook()
+	ob.apply(true,
+		[][2]string{{"99", "1"}, {"98", "2"}},
+		[][2]string{{"101", "1"}, {"102", "2"}})
+
+	// a delta updates, adds and removes levels
+	ob.apply(false,
+		[][2]string{{"99", "3"}, {"98", "0"}},
+		[][2]string{{"100.5", "0.5"}})
+	if len(ob.bids) != 1 || ob.bids[99] != 3 {
+		t.Errorf("bids after delta %v", ob.bids)
+	}
+	if len(ob.asks) != 3 || ob.asks[100.5] != 0.5 {
+		t.Errorf("asks after delta %v", ob.asks)
+	}
+	if ob.Updated().IsZero() {
+		t.Error("update time not set")
+	}
+
+	// a snapshot drops everything before it
+	ob.apply(true, [][2]string{{"50", "1"}}, [][2]string{{"51", "1"}})
+	if len(ob.bids) != 1 || len(ob.asks) != 1 || ob.asks[51] != 1 {
+		t.Errorf("book after snapshot %v / %v", ob.bids, ob.asks)
+	}
+}
+
+func TestOrderBookEstimate(t *testing.T) {
+	ob := NewOrderBook()
+	if _, ok := ob.Estimate("Buy", 1, 0); ok {
+		t.Error("estimate on an empty book")
+	}
+	ob.apply(true,
+		[][2]string{{"99", "1"}, {"98", "2"}},
+		[][2]string{{"101", "1"}, {"102", "2"}})
+
+	// buy 303 USDT: 1 @ 101 then 2 @ 102 would be 305, so 1 + 202/102
+	est, ok := ob.Estimate("Buy", 0, 303)
+	if !ok || !est.Complete {
+		t.Fatalf("buy by value %+v %v", est, ok)
+	}
+	qty := 1 + 202.0/102
+	if !near(est.Best, 101) || !near(est.AvgPrice, 303/qty) {
+		t.Errorf("buy by value %+v", est)
+	}
+	if !near(est.Spread, 2.0/100*100) {
+		t.Errorf("spread %v", est.Spread)
+	}
+	if !near(est.Slippage, (303/qty-101)/101*100) {
+		t.Errorf("slippage %v", est.Slippage)
+	}
+
+	// sell 2 coins: 1 @ 99 and 1 @ 98
+	est, ok = ob.Estimate("Sell", 2, 0)
+	if !ok || !est.Complete || !near(est.Best, 99) || !near(est.AvgPrice, 98.5) {
+		t.Errorf("sell by qty %+v %v", est, ok)
+	}
+	if !near(est.Slippage, 0.5/99*100) {
+		t.Errorf("sell slippage %v", est.Slippage)
+	}
+
+	// more than the visible book fills only part of it
+	est, ok = ob.Estimate("Sell", 5, 0)
+	if !ok || est.Complete || !near(est.AvgPrice, (99+196)/3.0) {
+		t.Errorf("sell past the book %+v %v", est, ok)
+	}
+}
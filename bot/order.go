@@ -0,0 +1,280 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	orderAttempts  = 4
+	maxLinkID      = 32 // OKX clOrdId, Bybit and Binance take 36
+	reconcileEvery = 2 * time.Second
+
+	intentPending = "pending"
+	intentPlaced  = "placed"
+)
+
+var errFillPending = errors.New("fill not confirmed yet, it is booked once the exchange reports it")
+
+// OrderIntent is written to the state file before an order is sent, so a run
+// that dies mid-order can look the order up by its link id on restart
+type OrderIntent struct {
+	LinkID     string
+	Side       string
+	TakeProfit bool
+	Qty        string
+	Price      float64
+	Status     string
+	OrderID    string
+	CreatedAt  time.Time
+
+	estimate *BookEstimate // the book it went out on, for the slippage log
+}
+
+// nextOrderLinkID builds a deterministic client order id from bot, deal and
+// step, e.g. BTCUSDT-1p5-3-B4. A bot id too long for the exchanges is
+// replaced by its hash, which is just as stable across restarts.
+func (b *DCABot) nextOrderLinkID(kind string) string {
+	b.DealStep++
+	id := fmt.Sprintf("%s-%d-%s%d", b.BotID, b.DealNumber, kind, b.DealStep)
+	if len(id) > maxLinkID {
+		id = fmt.Sprintf("%08x-%d-%s%d", crc32.ChecksumIEEE([]byte(b.BotID)), b.DealNumber, kind, b.DealStep)
+	}
+	return id
+}
+
+// submitOrder places an order tagged with the intent's link id. When the
+// outcome is unclear it asks the exchange about that id before retrying, so
+// a retry never doubles an order that already went through.
+func (b *DCABot) submitOrder(intent *OrderIntent, req OrderRequest) (*Order, error) {
+	req.LinkID = intent.LinkID
+	intent.Status = intentPending
+	intent.CreatedAt = time.Now()
+	b.Intents[intent.LinkID] = intent
+	b.saveState()
+
+	var lastErr error
+	ambiguous := false
+	for attempt := 0; attempt < orderAttempts; attempt++ {
+		order, err := b.Exchange.PlaceOrder(req)
+		if err == nil {
+			b.markPlaced(intent, order.ID)
+			return order, nil
+		}
+		lastErr = err
+
+		exErr, ok := asExchangeError(err)
+		answered := ok && exErr.Answered() && exErr.Kind != ErrDuplicateOrder
+		if answered && !exErr.Transient() {
+			// The exchange answered and turned the order down
+			delete(b.Intents, intent.LinkID)
+			b.saveState()
+			return nil, err
+		}
+
+		ambiguous = !answered
+		if ambiguous {
+			found, qerr := b.Exchange.GetOrderByLinkID(b.Symbol, intent.LinkID)
+			if qerr == nil {
+				if found.Created.Before(intent.CreatedAt.Add(-time.Minute)) {
+					delete(b.Intents, intent.LinkID)
+					b.saveState()
+					return nil, fmt.Errorf("order id %s already used by an older order", intent.LinkID)
+				}
+				b.markPlaced(intent, found.ID)
+				switch {
+				case found.Open():
+					return nil, errFillPending // the intent stays for reconcileIntents
+				case found.FilledQty == 0:
+					delete(b.Intents, intent.LinkID)
+					b.saveState()
+					return nil, fmt.Errorf("order %s %s without a fill", intent.LinkID, strings.ToLower(found.Status))
+				}
+				return found, nil
+			}
+			if !errors.Is(qerr, errOrderNotFound) {
+				log.Printf("%s order lookup %s error: %v", b.Exchange.Name(), intent.LinkID, qerr)
+			}
+		}
+
+		if attempt == orderAttempts-1 {
+			break
+		}
+		resyncAfter(err)
+		wait := backoff(attempt, err)
+		log.Printf("%s order %s attempt %d failed, retrying in %v: %v", b.Exchange.Name(), intent.LinkID, attempt+1, wait, err)
+		time.Sleep(wait)
+	}
+
+	if !ambiguous {
+		delete(b.Intents, intent.LinkID)
+		b.saveState()
+	}
+	// An ambiguous intent stays pending, the next reconcile settles it
+	return nil, lastErr
+}
+
+// handleOrderError alerts on a failed order and pauses the bot when retrying
+// cannot help
+func (b *DCABot) handleOrderError(what string, err error, token string) {
+	if errors.Is(err, errFillPending) {
+		sendTelegramMessage(token, fmt.Sprintf("⏳ %s %s sent, %v", b.Symbol, what, err))
+		return
+	}
+	exErr, ok := asExchangeError(err)
+	if ok && exErr.Kind == ErrMinNotional && what == b.entrySide() {
+		b.pauseBuys(fmt.Sprintf("a %s below the exchange minimum", strings.ToLower(what)), token)
+		return
+	}
+	if ok && exErr.Fatal() {
+		b.pause(fmt.Sprintf("%s failed: %s", what, exErr.Error()), token)
+		return
+	}
+	sendTelegramMessage(token, fmt.Sprintf("❗ %s %s failed: %v", b.Symbol, what, err))
+}
+
+// pause stops all new buys until /resume. Exits keep running, a position
+// must still be able to take profit or stop out.
+func (b *DCABot) pause(reason, token string) {
+	if b.Paused {
+		return
+	}
+	b.Paused = true
+	b.PauseReason = reason
+	b.saveState()
+
+	message := fmt.Sprintf("⏸️ %s DCA PAUSED\nReason: %s\nExits keep running. Fix the account and send /resume.", b.Symbol, reason)
+	log.Println(message)
+	sendTelegramMessage(token, message)
+}
+
+func (b *DCABot) markPlaced(intent *OrderIntent, orderID string) {
+	intent.OrderID = orderID
+	intent.Status = intentPlaced
+	b.saveState()
+}
+
+// finishIntent drops an intent once its order has been booked
+func (b *DCABot) finishIntent(linkID string) {
+	delete(b.Intents, linkID)
+	delete(b.fillBooks, linkID)
+	b.saveState()
+}
+
+// bookLater has reconcileIntents book the fill of an order that went out
+// without one through book rather than the plain buy or sell booking, and
+// then finish it with done
+func (b *DCABot) bookLater(linkID string, book func(price, qty, value float64), done func()) {
+	if b.fillBooks == nil {
+		b.fillBooks = map[string]func(price, qty, value float64){}
+	}
+	b.fillBooks[linkID] = func(price, qty, value float64) {
+		book(price, qty, value)
+		b.whenBooked(done)
+	}
+}
+
+// whenBooked runs done now, or after the last piece when the order was split
+func (b *DCABot) whenBooked(done func()) {
+	if done == nil {
+		return
+	}
+	if b.split != nil {
+		b.split.done = done
+		return
+	}
+	done()
+}
+
+// settleOrders moves along what is still in flight, fills to book and split
+// pieces to send, and reports whether anything is. Nothing else trades until
+// it is all booked.
+func (b *DCABot) settleOrders(token string) bool {
+	if len(b.Intents) > 0 {
+		if time.Since(b.lastReconcile) < reconcileEvery {
+			return true
+		}
+		b.lastReconcile = time.Now()
+		b.reconcileIntents(token)
+		if len(b.Intents) > 0 {
+			return true
+		}
+	}
+	if b.split != nil {
+		b.continueSplit(token)
+		return true
+	}
+	return false
+}
+
+// reconcileIntents asks the exchange about every order that was sent but not
+// booked, books whatever filled and forgets the ones that never arrived
+func (b *DCABot) reconcileIntents(token string) {
+	for linkID, intent := range b.Intents {
+		order, err := b.Exchange.GetOrderByLinkID(b.Symbol, linkID)
+		if errors.Is(err, errOrderNotFound) {
+			log.Printf("Order %s never reached %s, dropping it", linkID, b.Exchange.Name())
+			b.dropUnfilled(linkID)
+			continue
+		}
+		if err != nil {
+			log.Printf("Reconcile %s error: %v", linkID, err)
+			continue
+		}
+
+		if intent.TakeProfit {
+			// Hand it back to the take-profit tracker, which books its fills
+			b.TPOrderID = order.ID
+			b.TPOrderPrice = order.Price
+			b.TPOrderQty = order.Qty
+			b.TPFilledQty = 0
+			delete(b.Intents, linkID)
+			continue
+		}
+
+		if order.Open() {
+			continue // still working, check again later
+		}
+
+		qty := order.FilledQty
+		price := order.AvgPrice
+		if qty == 0 || price == 0 {
+			log.Printf("Order %s %s without a fill, dropping it", linkID, strings.ToLower(order.Status))
+			b.dropUnfilled(linkID)
+			continue
+		}
+
+		book, ok := b.fillBooks[linkID]
+		delete(b.Intents, linkID)
+		delete(b.fillBooks, linkID)
+		switch {
+		case ok:
+			book(price, qty, order.Value())
+		case intent.Side == b.entrySide():
+			b.bookBuy(price, qty, order.Value()/b.leverage())
+		case intent.Side == b.exitSide():
+			b.bookSell(price, qty)
+		}
+		if intent.estimate != nil {
+			b.logSlippage(intent.Side, *intent.estimate, order)
+		}
+
+		message := fmt.Sprintf("♻️ RECONCILED %s\nOrder: %s\nPrice: %.4f\nQty: %.6f\nStatus: %s",
+			intent.Side, linkID, price, qty, order.Status)
+		sendTelegramMessage(token, message)
+	}
+}
+
+// dropUnfilled forgets an order that never filled. When it was part of a
+// split, the rest of that order is off too.
+func (b *DCABot) dropUnfilled(linkID string) {
+	if _, ok := b.fillBooks[linkID]; ok {
+		b.split = nil
+	}
+	delete(b.Intents, linkID)
+	delete(b.fillBooks, linkID)
+}
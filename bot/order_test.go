@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDCABotID(t *testing.T) {
+	cases := []struct {
+		exchange, env, symbol string
+		drop                  float64
+		want                  string
+	}{
+		{"bybit", "tr", "btcusdt", 1.5, "BTCUSDT-1p5"},
+		{"bybit", "", "BTCUSDT", 2, "BTCUSDT-2"},
+		{"bybit", "testnet", "BTCUSDT", 2, "testnet-BTCUSDT-2"},
+		{"okx", "demo", "ETHUSDT", 0.75, "okx-demo-ETHUSDT-0p75"},
+	}
+	for _, c := range cases {
+		if got := dcaBotID(c.exchange, c.env, c.symbol, c.drop); got != c.want {
+			t.Errorf("dcaBotID(%s, %s, %s, %g) = %s, want %s", c.exchange, c.env, c.symbol, c.drop, got, c.want)
+		}
+	}
+}
+
+func TestLinkIDLength(t *testing.T) {
+	short := &DCABot{BotID: "BTCUSDT-1p5", DealNumber: 3}
+	if got := short.nextOrderLinkID("B"); got != "BTCUSDT-1p5-3-B1" {
+		t.Errorf("short link id = %s", got)
+	}
+
+	long := &DCABot{BotID: dcaBotID("binance-short", "testnet", "1000PEPEUSDT", 1.25), DealNumber: 12345, DealStep: 99}
+	first := long.nextOrderLinkID("S")
+	if len(first) > maxLinkID {
+		t.Fatalf("link id %s is %d chars, over %d", first, len(first), maxLinkID)
+	}
+	if strings.Contains(first, long.BotID) {
+		t.Errorf("link id %s kept the long bot id", first)
+	}
+
+	again := &DCABot{BotID: long.BotID, DealNumber: 12345, DealStep: 99}
+	if second := again.nextOrderLinkID("S"); second != first {
+		t.Errorf("link ids differ across restarts: %s and %s", first, second)
+	}
+}
+
+func TestScheduledBotID(t *testing.T) {
+	daily, _ := ParseSchedule("every 24h")
+	weekly, _ := ParseSchedule("0 9 * * 1")
+
+	a := scheduledBotID("bybit", "tr", "BTCUSDT", daily)
+	b := scheduledBotID("bybit", "tr", "BTCUSDT", weekly)
+	if a == b {
+		t.Fatalf("two schedules share the id %s", a)
+	}
+	if again, _ := ParseSchedule("every 24h"); scheduledBotID("bybit", "tr", "BTCUSDT", again) != a {
+		t.Error("the same schedule got a new id")
+	}
+	if strings.ContainsAny(b, " *") {
+		t.Errorf("id %s is not usable in an order link id", b)
+	}
+}
+
+func TestOrderValue(t *testing.T) {
+	if v := (&Order{FilledQty: 2, AvgPrice: 10, FilledValue: 20.5}).Value(); v != 20.5 {
+		t.Errorf("reported value %v", v)
+	}
+	if v := (&Order{FilledQty: 2, AvgPrice: 10}).Value(); v != 20 {
+		t.Errorf("value from the average price %v", v)
+	}
+}
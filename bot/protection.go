@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Stop Loss & Drawdown Protection
+////////////////////////////////////////////////////////////
+
+// ProtectAction is what a protection does when it triggers
+type ProtectAction string
+
+const (
+	ProtectSellAll   ProtectAction = "sell"
+	ProtectPauseBuys ProtectAction = "pause"
+	ProtectAlert     ProtectAction = "alert"
+)
+
+func ParseProtectAction(s string) (ProtectAction, error) {
+	switch a := ProtectAction(strings.ToLower(strings.TrimSpace(s))); a {
+	case "":
+		return ProtectPauseBuys, nil
+	case ProtectSellAll, ProtectPauseBuys, ProtectAlert:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown action %q, use sell, pause or alert", s)
+}
+
+const (
+	protectStopLoss = "stop loss"
+	protectDrawdown = "max drawdown"
+	protectFloor    = "price floor"
+
+	protectRetryAfter = 30 * time.Second // between sells of a triggered protection
+)
+
+// checkProtections runs the downside checks on every price and reports
+// whether the position was sold, in which case nothing else should run
+func (b *DCABot) checkProtections(price float64, token string) bool {
+	avg := b.avgBuyPrice()
+
+	if b.StopLossPercent > 0 && avg > 0 {
+		if move := b.adverseMove(avg, price); move >= b.StopLossPercent {
+			reason := fmt.Sprintf("Price %.4f is %.2f%% against the %.4f average", price, move, avg)
+			if b.protect(protectStopLoss, b.StopLossAction, price, reason, token) {
+				return true
+			}
+		}
+	}
+
+	if b.MaxDrawdownUSDT > 0 && avg > 0 {
+		if pnl, _ := b.UnrealizedPNL(price); -pnl >= b.MaxDrawdownUSDT {
+			reason := fmt.Sprintf("Unrealized loss %.2f USDT reached the %.2f USDT limit", -pnl, b.MaxDrawdownUSDT)
+			if b.protect(protectDrawdown, b.DrawdownAction, price, reason, token) {
+				return true
+			}
+		}
+	}
+
+	if b.FloorPrice > 0 {
+		if price < b.FloorPrice {
+			reason := fmt.Sprintf("Price %.4f is below the %.4f floor, no buys until it is back above", price, b.FloorPrice)
+			if b.protect(protectFloor, b.FloorAction, price, reason, token) {
+				return true
+			}
+		} else if b.ProtectHit[protectFloor] {
+			delete(b.ProtectHit, protectFloor)
+			b.saveState()
+			sendTelegramMessage(token, fmt.Sprintf("✅ %s back above the %.4f floor at %.4f", b.Symbol, b.FloorPrice, price))
+		}
+	}
+	return false
+}
+
+// protect fires a protection once until it is re-armed by a new deal or
+// /resume, and reports whether it sold the position. A sell only counts once
+// it went through, a failed one is tried again every protectRetryAfter while
+// the trigger holds.
+func (b *DCABot) protect(name string, action ProtectAction, price float64, reason, token string) bool {
+	if b.ProtectHit[name] {
+		return false
+	}
+	if action == ProtectSellAll {
+		if b.split != nil && b.split.kind == "B" {
+			b.split = nil // no more buying into the drop
+		}
+		// An exit or fill still in flight is booked first, the sell takes
+		// whatever is left after it
+		if time.Now().Before(b.protectRetryAt) || b.split != nil || len(b.Intents) > 0 {
+			return false
+		}
+	}
+
+	title := fmt.Sprintf("🛑 %s %s TRIGGERED", b.Symbol, strings.ToUpper(name))
+	log.Printf("%s: %s", title, reason)
+
+	switch action {
+	case ProtectAlert:
+		b.markProtectHit(name)
+		sendTelegramMessage(token, fmt.Sprintf("%s\n%s\nAction: alert only", title, reason))
+		return false
+
+	case ProtectSellAll:
+		sendTelegramMessage(token, fmt.Sprintf("%s\n%s\nAction: selling everything and pausing buys", title, reason))
+		if !b.BuysPaused {
+			b.pauseBuys(name, token)
+		}
+		if b.totalHoldings() == 0 {
+			b.markProtectHit(name) // nothing to sell, the pause is all it can do
+			return false
+		}
+		// A stop can't wait for the book to refill, it skips the book guard
+		sold := b.executeSell(price, 1, false, token, func() {
+			b.markProtectHit(name)
+			b.Records = nil // drop any dust below the lot step
+			b.resetTrailing()
+			b.saveState()
+		})
+		if !sold {
+			b.protectRetryAt = time.Now().Add(protectRetryAfter)
+			sendTelegramMessage(token, fmt.Sprintf("❗ %s %s sell failed, retrying in %s while it holds", b.Symbol, name, protectRetryAfter))
+			return false
+		}
+		return true
+
+	default:
+		b.markProtectHit(name)
+		sendTelegramMessage(token, fmt.Sprintf("%s\n%s\nAction: buys paused", title, reason))
+		b.pauseBuys(name, token)
+		return false
+	}
+}
+
+func (b *DCABot) markProtectHit(name string) {
+	if b.ProtectHit == nil {
+		b.ProtectHit = map[string]bool{}
+	}
+	b.ProtectHit[name] = true
+	b.saveState()
+}
+
+// pauseBuys stops new buys while exits keep running. The floor lifts by
+// itself once the price recovers, everything else waits for /resume.
+func (b *DCABot) pauseBuys(name, token string) {
+	if name == protectFloor {
+		return
+	}
+	b.BuysPaused = true
+	b.BuysPausedReason = name
+	b.saveState()
+	sendTelegramMessage(token, fmt.Sprintf("⏸️ %s buys paused by %s. Send /resume to start buying again.", b.Symbol, name))
+}
+
+// buyBlocked reports why a buy must not go out right now, if it must not
+func (b *DCABot) buyBlocked(price float64) string {
+	if b.Paused {
+		return "paused: " + b.PauseReason
+	}
+	if b.BuysPaused {
+		return "buys paused by " + b.BuysPausedReason
+	}
+	if reason := b.dealBlocked(); reason != "" {
+		return reason
+	}
+	if reason := b.regimeBlocked(); reason != "" {
+		return reason
+	}
+	if b.FloorPrice > 0 && price < b.FloorPrice && b.FloorAction != ProtectAlert {
+		return fmt.Sprintf("price below the %.4f floor", b.FloorPrice)
+	}
+	return ""
+}
+
+// rearmProtections lets the per-deal protections fire again
+func (b *DCABot) rearmProtections() {
+	delete(b.ProtectHit, protectStopLoss)
+	delete(b.ProtectHit, protectDrawdown)
+}
+
+// resumeBuys answers /resume
+func (b *DCABot) resumeBuys() string {
+	if !b.BuysPaused && !b.Paused {
+		return fmt.Sprintf("%s buys are not paused", b.Symbol)
+	}
+	reason := b.BuysPausedReason
+	if b.Paused {
+		reason = b.PauseReason
+	}
+	b.Paused = false
+	b.PauseReason = ""
+	b.BuysPaused = false
+	b.BuysPausedReason = ""
+	b.rearmProtections()
+	b.saveState()
+	return fmt.Sprintf("▶️ %s buys resumed after %s", b.Symbol, reason)
+}
+
+// protectionStatus is the protection line for /status
+func (b *DCABot) protectionStatus() string {
+	var parts []string
+	if b.StopLossPercent > 0 {
+		parts = append(parts, fmt.Sprintf("SL -%.2f%% (%s)", b.StopLossPercent, b.StopLossAction))
+	}
+	if b.MaxDrawdownUSDT > 0 {
+		parts = append(parts, fmt.Sprintf("DD %.2f USDT (%s)", b.MaxDrawdownUSDT, b.DrawdownAction))
+	}
+	if b.FloorPrice > 0 {
+		parts = append(parts, fmt.Sprintf("Floor %.4f (%s)", b.FloorPrice, b.FloorAction))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	status := "Protections: " + strings.Join(parts, ", ")
+	if b.BuysPaused {
+		status += "\nBuys paused by " + b.BuysPausedReason
+	}
+	return status
+}
@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"dca-bot/config"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bybit "github.com/bybit-exchange/bybit.go.api"
+)
+
+// Priority decides who goes first when the budget runs low. Order calls can
+// dip into a reserve that market data never touches.
+type Priority int
+
+const (
+	PriorityMarketData Priority = iota
+	PriorityOrder
+)
+
+// limiterMaxWait is the longest a request queues for budget. Anything longer,
+// like a ban or an exhausted quota, fails at once rather than stall the
+// caller, which is usually the price loop.
+const limiterMaxWait = 2 * time.Second
+
+// RateLimiter keeps a fixed-window request budget for one exchange and key,
+// corrected by whatever usage the exchange reports back in its headers
+type RateLimiter struct {
+	mu      sync.Mutex
+	name    string
+	limit   int
+	reserve int
+	window  time.Duration
+
+	used          int
+	windowStart   time.Time
+	blockedUntil  time.Time
+	blocked       map[string]time.Time // per endpoint quotas
+	waitingOrders int
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*RateLimiter{}
+)
+
+// rateLimiterFor returns the limiter shared by every bot using this key, so
+// several bots in one process draw from the same budget
+func rateLimiterFor(exchange, apiKey string) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	id := exchange + ":" + apiKey
+	if l, ok := limiters[id]; ok {
+		return l
+	}
+
+	var l *RateLimiter
+	switch exchange {
+	case "binance":
+		// Futures allow 2400 weight per minute, keep a margin below it
+		l = &RateLimiter{name: exchange, limit: 2000, reserve: 200, window: time.Minute}
+	case "okx":
+		// OKX limits each endpoint to 20-60 requests per 2s
+		l = &RateLimiter{name: exchange, limit: 40, reserve: 10, window: 2 * time.Second}
+	case "binance-spot":
+		// Spot allows 6000 weight per minute
+		l = &RateLimiter{name: exchange, limit: 5000, reserve: 500, window: time.Minute}
+	default:
+		// Bybit allows 600 requests per 5s per IP
+		l = &RateLimiter{name: exchange, limit: 500, reserve: 50, window: 5 * time.Second}
+	}
+	limiters[id] = l
+	return l
+}
+
+// Wait blocks until a request of the given weight to endpoint fits in the
+// budget, or fails with a rate limit error when that is more than
+// limiterMaxWait away
+func (l *RateLimiter) Wait(p Priority, weight int, endpoint string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if p == PriorityOrder {
+		l.waitingOrders++
+		defer func() { l.waitingOrders-- }()
+	}
+
+	deadline := time.Now().Add(limiterMaxWait)
+	for {
+		now := time.Now()
+		if now.Sub(l.windowStart) >= l.window {
+			l.windowStart = now.Truncate(l.window)
+			l.used = 0
+		}
+
+		allowed := l.limit - l.reserve
+		if p == PriorityOrder {
+			allowed = l.limit
+		}
+		queued := p == PriorityMarketData && l.waitingOrders > 0
+
+		blockedUntil := l.blockedUntil
+		if t := l.blocked[endpoint]; t.After(blockedUntil) {
+			blockedUntil = t
+		}
+
+		if now.After(blockedUntil) && !queued && l.used+weight <= allowed {
+			l.used += weight
+			return nil
+		}
+
+		wait := l.windowStart.Add(l.window).Sub(now)
+		if blockedUntil.After(now) {
+			wait = blockedUntil.Sub(now)
+		}
+		if queued {
+			// Re-check often enough to notice the orders going out
+			wait = min(wait, 100*time.Millisecond)
+		}
+		if now.Add(wait).After(deadline) {
+			until := now.Add(wait)
+			return &ExchangeError{
+				Exchange: l.name,
+				Kind:     ErrRateLimit,
+				Message:  fmt.Sprintf("request budget exhausted until %s", until.Format("15:04:05")),
+			}
+		}
+
+		l.mu.Unlock()
+		time.Sleep(wait)
+		l.mu.Lock()
+	}
+}
+
+// Observe records the usage the exchange reported, which also covers calls
+// made by other processes on the same key
+func (l *RateLimiter) Observe(used int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if used > l.used {
+		l.used = used
+	}
+}
+
+// BlockUntil holds requests back, e.g. after a 429. An exhausted quota of a
+// single endpoint blocks only that endpoint, an empty one blocks them all.
+func (l *RateLimiter) BlockUntil(endpoint string, t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if endpoint == "" {
+		if t.After(l.blockedUntil) {
+			l.blockedUntil = t
+			log.Printf("%s rate limit reached, holding requests until %s", l.name, t.Format("15:04:05"))
+		}
+		return
+	}
+	if l.blocked == nil {
+		l.blocked = map[string]time.Time{}
+	}
+	if t.After(l.blocked[endpoint]) {
+		l.blocked[endpoint] = t
+		log.Printf("%s %s quota used up, holding it until %s", l.name, endpoint, t.Format("15:04:05"))
+	}
+}
+
+////////////////////////////////////////////////////////////
+// Binance
+////////////////////////////////////////////////////////////
+
+// binanceDo sends a Binance request through the shared limiter and feeds the
+// X-MBX-USED-WEIGHT-* headers back into it. Public calls count against the
+// same weight as signed ones, so both use the configured key's limiter.
+func binanceDo(req *http.Request, p Priority, weight int) (*http.Response, error) {
+	name := "binance"
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		name = "binance-spot" // spot and futures weight are counted apart
+	}
+	limiter := rateLimiterFor(name, config.BinanceApiKey)
+	if err := limiter.Wait(p, weight, ""); err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range resp.Header {
+		if strings.HasPrefix(strings.ToUpper(key), "X-MBX-USED-WEIGHT-") && len(values) > 0 {
+			if used, err := strconv.Atoi(values[0]); err == nil {
+				limiter.Observe(used)
+			}
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if retryAfter <= 0 {
+			retryAfter = 60
+		}
+		limiter.BlockUntil("", time.Now().Add(time.Duration(retryAfter)*time.Second))
+	}
+	return resp, nil
+}
+
+////////////////////////////////////////////////////////////
+// Bybit
+////////////////////////////////////////////////////////////
+
+// UseBybitRateLimit puts every call the client makes through the shared
+// limiter. Install it after UseBybitClock so requests are signed only once
+// they leave the queue.
+func UseBybitRateLimit(client *bybit.Client) {
+	base := client.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.HTTPClient.Transport = &bybitLimiter{
+		base:    base,
+		limiter: rateLimiterFor("bybit", client.APIKey),
+	}
+}
+
+type bybitLimiter struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *bybitLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := PriorityMarketData
+	if strings.HasPrefix(req.URL.Path, "/v5/order/") && req.Method == http.MethodPost {
+		p = PriorityOrder
+	}
+	if err := t.limiter.Wait(p, 1, req.URL.Path); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// X-Bapi-Limit-Status is what is left of this endpoint's quota
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Bapi-Limit-Status"))
+	if err == nil && remaining <= 0 {
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-Bapi-Limit-Reset-Timestamp"), 10, 64)
+		until := time.UnixMilli(reset)
+		if reset == 0 || until.Before(time.Now()) {
+			until = time.Now().Add(time.Second)
+		}
+		t.limiter.BlockUntil(req.URL.Path, until)
+	}
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		// Bybit answers an IP ban with a bare 403 and lifts it after 10 minutes
+		t.limiter.BlockUntil("", time.Now().Add(10*time.Minute))
+	}
+	return resp, nil
+}
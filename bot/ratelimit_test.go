@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterEndpointBlock(t *testing.T) {
+	l := &RateLimiter{name: "test", limit: 10, reserve: 2, window: time.Second}
+	l.BlockUntil("/v5/order/create", time.Now().Add(time.Minute))
+
+	start := time.Now()
+	err := l.Wait(PriorityOrder, 1, "/v5/order/create")
+	if exErr, ok := asExchangeError(err); !ok || exErr.Kind != ErrRateLimit {
+		t.Fatalf("blocked endpoint: got %v, want a rate limit error", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("blocked endpoint waited %v instead of failing fast", time.Since(start))
+	}
+
+	if err := l.Wait(PriorityMarketData, 1, "/v5/market/tickers"); err != nil {
+		t.Fatalf("other endpoint: %v", err)
+	}
+}
+
+func TestRateLimiterGlobalBlock(t *testing.T) {
+	l := &RateLimiter{name: "test", limit: 10, reserve: 2, window: time.Second}
+	l.BlockUntil("", time.Now().Add(10*time.Minute))
+
+	if err := l.Wait(PriorityMarketData, 1, "/v5/market/tickers"); err == nil {
+		t.Fatal("a banned key let a request through")
+	}
+
+	l.blockedUntil = time.Now().Add(200 * time.Millisecond)
+	if err := l.Wait(PriorityOrder, 1, ""); err != nil {
+		t.Fatalf("a short block should be waited out: %v", err)
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := &RateLimiter{name: "test", limit: 10, reserve: 2, window: time.Minute}
+	l.windowStart = time.Now()
+	l.used = 8
+
+	if err := l.Wait(PriorityMarketData, 1, ""); err == nil {
+		t.Fatal("market data dipped into the order reserve")
+	}
+	if err := l.Wait(PriorityOrder, 1, ""); err != nil {
+		t.Fatalf("order: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Market Regime
+////////////////////////////////////////////////////////////
+
+// Regime is the higher timeframe state of the market, it tells a dip in an
+// uptrend from a capitulation
+type Regime string
+
+const (
+	RegimeBull  Regime = "bull"
+	RegimeRange Regime = "range"
+	RegimeBear  Regime = "bear"
+)
+
+const (
+	regimeRefresh = time.Hour
+	regimeEMA     = 200 // daily
+	regimeWeekly  = 10  // weekly EMA the trend is read from
+	regimeHighVol = 80  // ATR percentile that counts as a crash when below the daily EMA
+)
+
+// RegimeRule is what a regime does to buys. The spacing scale multiplies the
+// drop step, the size scale the order sizes.
+type RegimeRule struct {
+	DropScale float64
+	SizeScale float64
+	Pause     bool
+}
+
+func (r RegimeRule) String() string {
+	if r.Pause {
+		return "buys paused"
+	}
+	return fmt.Sprintf("drop spacing x%g, order size x%g", r.DropScale, r.SizeScale)
+}
+
+// RegimeFilter adjusts buying to the regime, off unless Enabled
+type RegimeFilter struct {
+	Enabled bool
+	Bull    RegimeRule
+	Range   RegimeRule
+	Bear    RegimeRule
+}
+
+// regimeStats is what the regime was read from
+type regimeStats struct {
+	Close         float64
+	EMA           float64
+	WeeklyTrend   string
+	VolPercentile float64
+}
+
+// regimeRule is the rule for the current regime, neutral until one is known
+func (b *DCABot) regimeRule() RegimeRule {
+	if b.RegimeFilter.Enabled {
+		switch b.Regime {
+		case RegimeBull:
+			return b.RegimeFilter.Bull
+		case RegimeRange:
+			return b.RegimeFilter.Range
+		case RegimeBear:
+			return b.RegimeFilter.Bear
+		}
+	}
+	return RegimeRule{DropScale: 1, SizeScale: 1}
+}
+
+// sizeScale is everything order sizes are multiplied by: compounding and the
+// regime
+func (b *DCABot) sizeScale() float64 {
+	return b.orderScale() * b.regimeRule().SizeScale
+}
+
+// regimeBlocked holds buys back while the regime pauses them
+func (b *DCABot) regimeBlocked() string {
+	if b.regimeRule().Pause {
+		return fmt.Sprintf("%s regime", b.Regime)
+	}
+	return ""
+}
+
+// refreshRegime reads the regime once an hour and announces every change
+func (b *DCABot) refreshRegime(token string) {
+	if !b.RegimeFilter.Enabled || b.Candles == nil || time.Since(b.lastRegimeCheck) < regimeRefresh {
+		return
+	}
+	b.lastRegimeCheck = time.Now()
+
+	regime, stats, err := b.detectRegime()
+	if err != nil {
+		log.Printf("%s regime error: %v", b.Symbol, err)
+		return
+	}
+	b.regimeStats = stats
+	if regime == b.Regime {
+		return
+	}
+
+	previous := b.Regime
+	if previous == "" {
+		previous = "unknown"
+	}
+	b.Regime = regime
+	b.saveState()
+
+	message := fmt.Sprintf("🌦 %s REGIME: %s → %s\nClose: %.4f\nDaily %d EMA: %.4f\nWeekly trend: %s\nVolatility: %.0fth percentile\nBuys: %s",
+		b.Symbol, strings.ToUpper(string(previous)), strings.ToUpper(string(regime)),
+		stats.Close, regimeEMA, stats.EMA, stats.WeeklyTrend, stats.VolPercentile, b.regimeRule())
+	sendTelegramMessage(token, message)
+}
+
+// detectRegime classifies the market from daily and weekly candles: bear
+// below the daily 200 EMA with a falling weekly trend or crash-level
+// volatility, bull above it with a rising weekly trend, range otherwise
+func (b *DCABot) detectRegime() (Regime, regimeStats, error) {
+	var stats regimeStats
+
+	daily, err := b.Candles.Candles(b.Symbol, "1d", 400)
+	if err != nil {
+		return "", stats, fmt.Errorf("daily candles: %w", err)
+	}
+	if len(daily) < regimeEMA {
+		return "", stats, fmt.Errorf("only %d daily candles, the %d EMA needs more history", len(daily), regimeEMA)
+	}
+	weekly, err := b.Candles.Candles(b.Symbol, "1w", 30)
+	if err != nil {
+		return "", stats, fmt.Errorf("weekly candles: %w", err)
+	}
+
+	closes := make([]float64, len(daily))
+	for i, c := range daily {
+		closes[i] = c.Close
+	}
+	stats.Close = closes[len(closes)-1]
+	stats.EMA = ema(closes, regimeEMA)
+	stats.WeeklyTrend = weeklyTrend(weekly)
+	stats.VolPercentile = volPercentile(daily, 14)
+
+	below := stats.Close < stats.EMA
+	switch {
+	case below && (stats.WeeklyTrend == "down" || stats.VolPercentile >= regimeHighVol):
+		return RegimeBear, stats, nil
+	case !below && stats.WeeklyTrend == "up":
+		return RegimeBull, stats, nil
+	}
+	return RegimeRange, stats, nil
+}
+
+// weeklyTrend is up when the weekly close is above a rising 10 week EMA,
+// down when below a falling one, flat otherwise
+func weeklyTrend(weekly []Candle) string {
+	if len(weekly) < regimeWeekly+4 {
+		return "flat"
+	}
+	closes := make([]float64, len(weekly))
+	for i, c := range weekly {
+		closes[i] = c.Close
+	}
+	now := ema(closes, regimeWeekly)
+	before := ema(closes[:len(closes)-4], regimeWeekly)
+	last := closes[len(closes)-1]
+	switch {
+	case last > now && now > before:
+		return "up"
+	case last < now && now < before:
+		return "down"
+	}
+	return "flat"
+}
+
+// volPercentile ranks today's ATR, as a percentage of price, against every
+// earlier day the candles cover
+func volPercentile(daily []Candle, period int) float64 {
+	ranges := make([]FixRangeCandle, len(daily))
+	for i, c := range daily {
+		ranges[i] = FixRangeCandle{High: c.High, Low: c.Low, Close: c.Close}
+	}
+
+	var history []float64
+	for end := period + 1; end <= len(ranges); end++ {
+		history = append(history, calculateATR(ranges[:end], period)/ranges[end-1].Close*100)
+	}
+	if len(history) < 2 {
+		return 0
+	}
+	current := history[len(history)-1]
+	sort.Float64s(history)
+	below := sort.SearchFloat64s(history, current)
+	return float64(below) / float64(len(history)-1) * 100
+}
+
+// regimeStatus is the regime line for /status and the daily report
+func (b *DCABot) regimeStatus() string {
+	if !b.RegimeFilter.Enabled {
+		return ""
+	}
+	if b.Regime == "" {
+		return "Regime: not read yet"
+	}
+	s := b.regimeStats
+	status := fmt.Sprintf("Regime: %s, %s", b.Regime, b.regimeRule())
+	if s.EMA > 0 {
+		status += fmt.Sprintf("\n%d EMA %.4f, weekly %s, volatility p%.0f", regimeEMA, s.EMA, s.WeeklyTrend, s.VolPercentile)
+	}
+	return status
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////
+// Profit Reinvestment
+////////////////////////////////////////////////////////////
+
+// ReinvestPolicy decides what realized profit does to later buys
+type ReinvestPolicy string
+
+const (
+	ReinvestFixed    ReinvestPolicy = "fixed"    // profit returns to the budget, order sizes stay put
+	ReinvestCompound ReinvestPolicy = "compound" // order sizes grow and shrink with equity
+	ReinvestVault    ReinvestPolicy = "vault"    // VaultPercent of each profit is locked away
+)
+
+func ParseReinvestPolicy(s string) (ReinvestPolicy, error) {
+	switch p := ReinvestPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return ReinvestFixed, nil
+	case ReinvestFixed, ReinvestCompound, ReinvestVault:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown reinvest policy %q, use fixed, compound or vault", s)
+}
+
+// bookProfit applies the policy to the PNL of a sell whose proceeds were
+// already added to TotalUSDT
+func (b *DCABot) bookProfit(realizedPNL float64) {
+	if realizedPNL <= 0 {
+		return
+	}
+	skim := 0.0
+	if b.Reinvest == ReinvestVault {
+		skim = realizedPNL * b.VaultPercent / 100
+		b.Vault += skim
+		b.TotalUSDT -= skim
+	}
+	b.Reinvested += realizedPNL - skim
+}
+
+// orderScale is what compounding multiplies order sizes by: the bot's equity,
+// cash left plus the capital tied up in the position, against what it
+// started with
+func (b *DCABot) orderScale() float64 {
+	if b.Reinvest != ReinvestCompound || b.InitialUSDT <= 0 {
+		return 1
+	}
+	return (b.TotalUSDT + b.totalCost()/b.leverage()) / b.InitialUSDT
+}
+
+// reinvestStatus is the reinvestment part of the daily report and /status
+func (b *DCABot) reinvestStatus() string {
+	status := fmt.Sprintf("Reinvested: %.2f USDT (%s)", b.Reinvested, b.Reinvest)
+	switch b.Reinvest {
+	case ReinvestCompound:
+		status += fmt.Sprintf("\nOrder size: x%.2f", b.orderScale())
+	case ReinvestVault:
+		status += fmt.Sprintf("\nVault: %.2f USDT (%.0f%% of profits)", b.Vault, b.VaultPercent)
+	}
+	return status
+}
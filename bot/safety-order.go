@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////
+// Scaled Safety Orders
+////////////////////////////////////////////////////////////
+
+// SafetyStep is one rung of the safety order ladder. Deviation is measured
+// from the deal's base order price.
+type SafetyStep struct {
+	Number     int
+	Deviation  float64 // %
+	OrderUSDT  float64
+	TotalUSDT  float64 // base order plus every safety order up to this one
+	AvgPercent float64 // average entry below the base price after this fill, %
+}
+
+// safetyEnabled reports whether buys follow the ladder instead of the flat
+// OneBuyUSDT on every drop
+func (b *DCABot) safetyEnabled() bool {
+	return b.MaxSafetyOrders > 0 && b.SafetyOrderUSDT > 0
+}
+
+// SafetyLadder works out every safety order of a deal up front. Each order
+// is VolumeScale times the previous one and sits StepScale times further
+// from the one before it.
+func (b *DCABot) SafetyLadder() []SafetyStep {
+	var ladder []SafetyStep
+
+	base := b.BaseOrderUSDT
+	total := base
+	coins := base // base order bought at a price of 1
+	deviation := 0.0
+	step := b.dropStep()
+	size := b.SafetyOrderUSDT
+
+	for i := 1; i <= b.MaxSafetyOrders; i++ {
+		deviation += step
+		if deviation >= 100 {
+			break
+		}
+		price := 1 - deviation/100
+
+		total += size
+		coins += size / price
+
+		ladder = append(ladder, SafetyStep{
+			Number:     i,
+			Deviation:  deviation,
+			OrderUSDT:  size,
+			TotalUSDT:  total,
+			AvgPercent: (1 - total/coins) * 100,
+		})
+
+		step *= b.StepScale
+		size *= b.VolumeScale
+	}
+	return ladder
+}
+
+// nextSafetyPrice is where the next safety order fires, or 0 once the
+// ladder is used up
+func (b *DCABot) nextSafetyPrice() float64 {
+	ladder := b.SafetyLadder()
+	if b.SafetyCount >= len(ladder) || b.BasePrice == 0 {
+		return 0
+	}
+	return b.BasePrice * (1 - b.dir()*ladder[b.SafetyCount].Deviation/100)
+}
+
+// nextOrderUSDT sizes the next buy: the base order for a fresh deal, then
+// the safety orders in turn, scaled when profits compound
+func (b *DCABot) nextOrderUSDT() float64 {
+	if !b.safetyEnabled() {
+		return b.OneBuyUSDT * b.sizeScale()
+	}
+	if b.totalHoldings() == 0 {
+		return b.BaseOrderUSDT * b.sizeScale()
+	}
+	return b.SafetyOrderUSDT * math.Pow(b.VolumeScale, float64(b.SafetyCount)) * b.sizeScale()
+}
+
+// LadderSummary prints the planned ladder with the capital a full deal needs
+// and the drop it can absorb
+func (b *DCABot) LadderSummary() string {
+	var sb strings.Builder
+
+	ladder := b.SafetyLadder()
+	fmt.Fprintf(&sb, "Base order: %.2f USDT\n", b.BaseOrderUSDT)
+	for _, s := range ladder {
+		fmt.Fprintf(&sb, "SO #%-2d  -%6.2f%%  %10.2f USDT  total %10.2f USDT  avg -%.2f%%\n",
+			s.Number, s.Deviation, s.OrderUSDT, s.TotalUSDT, s.AvgPercent)
+	}
+
+	required, covered := b.BaseOrderUSDT, 0.0
+	if len(ladder) > 0 {
+		last := ladder[len(ladder)-1]
+		required, covered = last.TotalUSDT, last.Deviation
+	}
+	fmt.Fprintf(&sb, "Capital required: %.2f USDT\n", required)
+	fmt.Fprintf(&sb, "Deviation covered: %.2f%%\n", covered)
+	if required > b.TotalUSDT {
+		fmt.Fprintf(&sb, "⚠️ Needs %.2f USDT more than the %.2f USDT budget\n", required-b.TotalUSDT, b.TotalUSDT)
+	}
+	return sb.String()
+}
@@ -0,0 +1,328 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Scheduled Recurring Buys
+////////////////////////////////////////////////////////////
+
+// Schedule tells when the next recurring buy is due
+type Schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// CatchUpPolicy decides what happens to buys missed while the bot was down
+type CatchUpPolicy string
+
+const (
+	CatchUpSkip CatchUpPolicy = "skip" // drop them and wait for the next one
+	CatchUpOnce CatchUpPolicy = "once" // make up for all of them with one normal buy
+	CatchUpAll  CatchUpPolicy = "all"  // buy every missed amount in one order
+)
+
+// scheduleGrace is how late a buy may run and still count as on time,
+// trades can be sparse on quiet markets
+const scheduleGrace = 10 * time.Minute
+
+func ParseCatchUp(s string) (CatchUpPolicy, error) {
+	switch p := CatchUpPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return CatchUpOnce, nil
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown catch-up rule %q, use skip, once or all", s)
+}
+
+// ParseSchedule reads "every 24h" / "every 1w" style intervals or a five
+// field cron line such as "0 9 * * 1" (Mondays 09:00, local time)
+func ParseSchedule(s string) (Schedule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if rest, ok := strings.CutPrefix(strings.ToLower(s), "every "); ok {
+		rest = strings.TrimSpace(rest)
+		d := intervalDuration(rest)
+		if d == 0 {
+			var err error
+			if d, err = time.ParseDuration(rest); err != nil {
+				return nil, fmt.Errorf("bad interval %q, use e.g. every 24h or every 1w", rest)
+			}
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("interval %q is shorter than a minute", rest)
+		}
+		return intervalSchedule{every: d, spec: s}, nil
+	}
+	return parseCron(s)
+}
+
+// intervalSchedule buys every fixed duration on slots aligned to UTC
+// (midnights for days, Mondays for weeks) so they stay put across restarts
+type intervalSchedule struct {
+	every time.Duration
+	spec  string
+}
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Truncate(s.every).Add(s.every)
+}
+
+func (s intervalSchedule) String() string {
+	return s.spec
+}
+
+// cronSchedule is a classic minute hour day-of-month month day-of-week line
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+	spec                          string
+}
+
+func parseCron(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday)", spec)
+	}
+
+	c := cronSchedule{spec: spec, domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron hour: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron day of month: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron month: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron weekday: %w", err)
+	}
+	if c.dow[7] {
+		c.dow[0] = true // both 0 and 7 are Sunday
+	}
+	return c, nil
+}
+
+// parseCronField expands *, lists, ranges and steps such as "1-5" or "*/15"
+func parseCronField(field string, lo, hi int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return nil, fmt.Errorf("bad step in %q", part)
+			}
+		}
+
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("bad value in %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("bad range in %q", part)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, lo, hi)
+		}
+
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func (c cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Walk day by day, then minute by minute within a matching day. Five
+	// years covers any valid line, including Feb 29.
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		if !c.month[int(t.Month())] || !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted either one
+// matching is enough
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c cronSchedule) String() string {
+	return "cron " + c.spec
+}
+
+// scheduleEnabled reports whether buys follow the clock instead of drops
+func (b *DCABot) scheduleEnabled() bool {
+	return b.Schedule != nil && (b.ScheduleUSDT > 0 || b.ValueStepUSDT > 0)
+}
+
+// checkSchedule places the recurring buy once its slot has come, folding in
+// whatever the catch-up rule makes of slots missed while the bot was down.
+// A refused buy keeps its slot and is retried until it goes out.
+func (b *DCABot) checkSchedule(price float64, token string) {
+	now := time.Now()
+	if b.NextScheduledBuy.IsZero() {
+		b.NextScheduledBuy = b.Schedule.Next(now)
+		b.saveState()
+		fmt.Printf("SCHEDULE %s → first buy at %s\n", b.Schedule, b.NextScheduledBuy.Format("2006-01-02 15:04"))
+		return
+	}
+	if now.Before(b.NextScheduledBuy) || now.Before(b.buyRetryAt) {
+		return
+	}
+
+	// Count the slots that have passed, the latest may still be on time
+	due, missed := 0, 0
+	next := b.NextScheduledBuy
+	for !next.After(now) && !next.IsZero() {
+		if now.Sub(next) > scheduleGrace && !next.Equal(b.scheduleHeld) {
+			missed++ // a held slot stays due, it was only refused
+		} else {
+			due++
+		}
+		next = b.Schedule.Next(next)
+	}
+
+	if b.valueAveraging() {
+		// The path moves on regardless, a refused buy leaves a gap the
+		// next slots close
+		b.NextScheduledBuy = next
+		b.saveState()
+		b.valueAverage(due+missed, price, token)
+		return
+	}
+
+	buys := due
+	switch {
+	case missed == 0:
+	case b.CatchUp == CatchUpAll:
+		buys += missed
+	case b.CatchUp == CatchUpOnce && due == 0:
+		buys = 1
+	}
+
+	retry := b.scheduleHeld.Equal(b.NextScheduledBuy)
+	if missed > 0 && !retry {
+		message := fmt.Sprintf("⏰ %s missed %d scheduled buy(s)\nCatch-up: %s, buying %d now\nNext: %s",
+			b.Symbol, missed, b.CatchUp, buys, next.Format("2006-01-02 15:04"))
+		sendTelegramMessage(token, message)
+	}
+	if buys == 0 {
+		b.NextScheduledBuy = next
+		b.saveState()
+		return
+	}
+
+	usdt := b.ScheduleUSDT * float64(buys) * b.sizeScale()
+	if boost, avg := b.scheduleBoost(price); boost > 1 {
+		usdt *= boost
+		if !retry {
+			message := fmt.Sprintf("🚀 %s BOOSTED BUY x%.1f\nPrice %.4f is %.2f%% under the 30-day average %.4f",
+				b.Symbol, boost, price, (1-price/avg)*100, avg)
+			sendTelegramMessage(token, message)
+		}
+	}
+	if usdt > b.TotalUSDT && b.TotalUSDT >= b.ScheduleUSDT {
+		usdt = b.TotalUSDT // a catch-up or boost never blocks the plain buy
+	}
+
+	fmt.Printf("SCHEDULED BUY → %.2f USDT at %.4f\n", usdt, price)
+	if !b.executeBuy(price, usdt, token) {
+		if !retry {
+			b.scheduleHeld = b.NextScheduledBuy
+			message := fmt.Sprintf("⏰ %s scheduled buy of %s not placed, retrying every %s until it goes out",
+				b.Symbol, b.NextScheduledBuy.Format("2006-01-02 15:04"), buyRetryAfter)
+			sendTelegramMessage(token, message)
+		}
+		return
+	}
+	b.NextScheduledBuy = next
+	b.scheduleHeld = time.Time{}
+	b.LastBuyPrice = price
+	b.LastBuyTime = now
+	b.Started = true
+	b.saveState()
+}
+
+// scheduleBoost is the multiplier for this buy and the 30-day average it was
+// measured against. Without a boost rule or candles it is 1.
+func (b *DCABot) scheduleBoost(price float64) (boost, avg float64) {
+	if b.BoostPercent <= 0 || b.Candles == nil {
+		return 1, 0
+	}
+	candles, err := b.Candles.Candles(b.Symbol, "1d", 30)
+	if err != nil || len(candles) == 0 {
+		log.Printf("%s 30-day average error: %v", b.Symbol, err)
+		return 1, 0
+	}
+	for _, c := range candles {
+		avg += c.Close
+	}
+	avg /= float64(len(candles))
+
+	if price > avg*(1-b.BoostPercent/100) {
+		return 1, avg
+	}
+	return b.BoostMultiplier, avg
+}
+
+// scheduleStatus is the schedule line for /status and the daily report
+func (b *DCABot) scheduleStatus() string {
+	if !b.scheduleEnabled() {
+		return ""
+	}
+	if b.valueAveraging() {
+		return b.valueStatus()
+	}
+	status := fmt.Sprintf("Schedule: %.2f USDT %s, next %s", b.ScheduleUSDT, b.Schedule, b.NextScheduledBuy.Format("2006-01-02 15:04"))
+	if b.BoostPercent > 0 {
+		status += fmt.Sprintf("\nBoost: x%.1f when %.2f%% under the 30-day average", b.BoostMultiplier, b.BoostPercent)
+	}
+	return status
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Short DCA on Perpetuals
+////////////////////////////////////////////////////////////
+
+// A short bot mirrors the spot bot: it sells to open, adds as the price
+// rises by DropPercent and buys back below the average entry. Records hold
+// the short entries, USDTSpent being the margin each one ties up.
+
+// dir is +1 for long and -1 for short, the sign of a profitable move
+func (b *DCABot) dir() float64 {
+	if b.Short {
+		return -1
+	}
+	return 1
+}
+
+// leverage is what entries are multiplied by, 1 on spot
+func (b *DCABot) leverage() float64 {
+	if !b.Short || b.Leverage <= 0 {
+		return 1
+	}
+	return b.Leverage
+}
+
+// entrySide opens or adds to the position, exitSide reduces it
+func (b *DCABot) entrySide() string {
+	if b.Short {
+		return "Sell"
+	}
+	return "Buy"
+}
+
+func (b *DCABot) exitSide() string {
+	if b.Short {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+// adverseMove is how far price has moved against the position from ref, in
+// percent: a drop for longs, a rise for shorts
+func (b *DCABot) adverseMove(ref, price float64) float64 {
+	return b.dir() * (ref - price) / ref * 100
+}
+
+// SetupFutures applies the leverage and margin mode before a short bot
+// starts trading
+func SetupFutures(futures FuturesExchange, cfg DCAConfig) error {
+	if err := futures.SetMarginMode(cfg.Symbol, cfg.MarginMode, cfg.Leverage); err != nil {
+		return fmt.Errorf("set %s margin: %w", cfg.MarginMode, err)
+	}
+	if err := futures.SetLeverage(cfg.Symbol, cfg.Leverage); err != nil {
+		return fmt.Errorf("set %gx leverage: %w", cfg.Leverage, err)
+	}
+	return nil
+}
+
+// checkPosition watches the exchange position of a short bot: how close it
+// is to liquidation and what funding has settled. It runs at most every 30s.
+func (b *DCABot) checkPosition(price float64, token string) {
+	if b.Futures == nil || time.Since(b.lastPositionCheck) < 30*time.Second {
+		return
+	}
+	b.lastPositionCheck = time.Now()
+
+	b.bookFunding(token)
+
+	if b.totalHoldings() == 0 {
+		return
+	}
+	pos, err := b.Futures.Position(b.Symbol, b.entrySide())
+	if err != nil {
+		log.Printf("%s position error: %v", b.Symbol, err)
+		return
+	}
+	if pos.Size == 0 {
+		b.pause("the exchange shows no position while the bot holds one, it was likely liquidated or closed by hand", token)
+		return
+	}
+	b.LiqPrice = pos.LiqPrice
+	if b.LiqAlertPercent <= 0 || pos.LiqPrice <= 0 {
+		return
+	}
+
+	mark := pos.MarkPrice
+	if mark == 0 {
+		mark = price
+	}
+	distance := math.Abs(pos.LiqPrice-mark) / mark * 100
+	switch {
+	case distance <= b.LiqAlertPercent && !b.liqAlerted:
+		b.liqAlerted = true
+		message := fmt.Sprintf("💀 %s LIQUIDATION RISK\nMark: %.4f\nLiquidation: %.4f\nDistance: %.2f%%\nSize: %.6f @ %.4f (%gx)",
+			b.Symbol, mark, pos.LiqPrice, distance, pos.Size, pos.AvgPrice, pos.Leverage)
+		sendTelegramMessage(token, message)
+	case distance > b.LiqAlertPercent*1.5 && b.liqAlerted:
+		b.liqAlerted = false // far enough again to warn on the next approach
+		sendTelegramMessage(token, fmt.Sprintf("✅ %s liquidation distance back to %.2f%%", b.Symbol, distance))
+	}
+}
+
+// bookFunding adds funding settled since the last check to the PNL, the
+// budget and the open deal
+func (b *DCABot) bookFunding(token string) {
+	if b.LastFundingTime.IsZero() {
+		b.LastFundingTime = time.Now() // funding from before the bot is not ours
+		b.saveState()
+		return
+	}
+	if time.Since(b.lastFundingCheck) < 10*time.Minute {
+		return
+	}
+	b.lastFundingCheck = time.Now()
+
+	payments, err := b.Futures.FundingSince(b.Symbol, b.LastFundingTime)
+	if err != nil {
+		log.Printf("%s funding error: %v", b.Symbol, err)
+		return
+	}
+	if len(payments) == 0 {
+		return
+	}
+
+	total := 0.0
+	for _, f := range payments {
+		total += f.Amount
+		if f.Time.After(b.LastFundingTime) {
+			b.LastFundingTime = f.Time
+		}
+	}
+	b.FundingPNL += total
+	b.RealizedPNL += total
+	b.TotalUSDT += total
+	if b.Deal != nil {
+		b.Deal.RealizedPNL += total
+	}
+	b.saveState()
+	b.recordLedger("Funding", 0, 0, 0, total, "")
+
+	message := fmt.Sprintf("💸 %s FUNDING\nSettled: %.4f USDT\nTotal funding: %.4f USDT", b.Symbol, total, b.FundingPNL)
+	sendTelegramMessage(token, message)
+}
+
+// shortStatus is the perpetuals line for /status and the daily report
+func (b *DCABot) shortStatus() string {
+	if !b.Short {
+		return ""
+	}
+	status := fmt.Sprintf("Short: %gx %s margin\nFunding: %.4f USDT", b.Leverage, b.MarginMode, b.FundingPNL)
+	if b.LiqPrice > 0 && b.totalHoldings() > 0 {
+		status += fmt.Sprintf("\nLiquidation: %.4f", b.LiqPrice)
+	}
+	return status
+}
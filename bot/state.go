@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"fmt"
+	"hash/crc32"
+	"log"
+	"strings"
+	"time"
+)
+
+// StateStore persists a bot's state between runs
+type StateStore interface {
+	SaveState(id string, state any) error
+	LoadState(id string, state any) (bool, error)
+}
+
+type dcaState struct {
+	TotalUSDT    float64
+	RealizedPNL  float64
+	LastBuyPrice float64
+	LastBuyTime  time.Time
+	Started      bool
+	Records      []DCARecord
+
+	DealNumber int
+	DealStep   int
+	Intents    map[string]*OrderIntent
+
+	TPOrderID    string
+	TPOrderPrice float64
+	TPOrderQty   float64
+	TPFilledQty  float64
+
+	BasePrice   float64
+	SafetyCount int
+
+	TrailingActive bool
+	TrailingHigh   float64
+
+	TrailingBuyKind  string
+	TrailingBuyLow   float64
+	TrailingBuySince time.Time
+
+	LotSells int
+	LotPNL   float64
+
+	Regime Regime
+
+	TPLevel int
+
+	ProtectHit       map[string]bool
+	BuysPaused       bool
+	BuysPausedReason string
+	Paused           bool
+	PauseReason      string
+
+	NextScheduledBuy time.Time
+	ValuePeriods     int
+
+	Deal           *Deal
+	Deals          []Deal
+	LastDealClosed time.Time
+
+	Vault       float64
+	Reinvested  float64
+	InitialUSDT float64
+
+	FundingPNL      float64
+	LastFundingTime time.Time
+}
+
+// dcaBotID is stable across restarts of the same setup so that saved state
+// and client order ids line up with the previous run. Bybit ids predate the
+// other exchanges and the tr hosts predate environments, both stay unmarked
+// so existing state still loads.
+func dcaBotID(exchange, env, symbol string, dropPercent float64) string {
+	return botID(exchange, env, symbol, fmt.Sprintf("%g", dropPercent))
+}
+
+// scheduledBotID keys a scheduled bot by its schedule, it has no drop
+// percent to tell two of them apart
+func scheduledBotID(exchange, env, symbol string, s Schedule) string {
+	return botID(exchange, env, symbol, fmt.Sprintf("s%08x", crc32.ChecksumIEEE([]byte(s.String()))))
+}
+
+func botID(exchange, env, symbol, key string) string {
+	id := strings.ToUpper(symbol) + "-" + key
+	if env != "" && env != "tr" {
+		id = env + "-" + id // testnet state must never meet mainnet state
+	}
+	if exchange != "bybit" {
+		id = exchange + "-" + id
+	}
+	return strings.ReplaceAll(id, ".", "p") // orderLinkId forbids dots
+}
+
+func (b *DCABot) saveState() {
+	if b.Store == nil {
+		return
+	}
+
+	state := dcaState{
+		TotalUSDT:    b.TotalUSDT,
+		RealizedPNL:  b.RealizedPNL,
+		LastBuyPrice: b.LastBuyPrice,
+		LastBuyTime:  b.LastBuyTime,
+		Started:      b.Started,
+		Records:      b.Records,
+		DealNumber:   b.DealNumber,
+		DealStep:     b.DealStep,
+		Intents:      b.Intents,
+		TPOrderID:    b.TPOrderID,
+		TPOrderPrice: b.TPOrderPrice,
+		TPOrderQty:   b.TPOrderQty,
+		TPFilledQty:  b.TPFilledQty,
+		BasePrice:    b.BasePrice,
+		SafetyCount:  b.SafetyCount,
+
+		TrailingActive: b.TrailingActive,
+		TrailingHigh:   b.TrailingHigh,
+
+		TrailingBuyKind:  b.TrailingBuyKind,
+		TrailingBuyLow:   b.TrailingBuyLow,
+		TrailingBuySince: b.TrailingBuySince,
+
+		LotSells: b.LotSells,
+		LotPNL:   b.LotPNL,
+
+		Regime: b.Regime,
+
+		TPLevel: b.TPLevel,
+
+		ProtectHit:       b.ProtectHit,
+		BuysPaused:       b.BuysPaused,
+		BuysPausedReason: b.BuysPausedReason,
+		Paused:           b.Paused,
+		PauseReason:      b.PauseReason,
+
+		NextScheduledBuy: b.NextScheduledBuy,
+		ValuePeriods:     b.ValuePeriods,
+
+		Deal:           b.Deal,
+		Deals:          b.Deals,
+		LastDealClosed: b.LastDealClosed,
+
+		Vault:       b.Vault,
+		Reinvested:  b.Reinvested,
+		InitialUSDT: b.InitialUSDT,
+
+		FundingPNL:      b.FundingPNL,
+		LastFundingTime: b.LastFundingTime,
+	}
+	if err := b.Store.SaveState(b.BotID, state); err != nil {
+		log.Printf("Save state error: %v", err)
+	}
+}
+
+// restoreState loads the previous run, if any, and settles orders that were
+// in flight when it stopped
+func (b *DCABot) restoreState(token string) {
+	if b.Store == nil {
+		return
+	}
+
+	var state dcaState
+	found, err := b.Store.LoadState(b.BotID, &state)
+	if err != nil {
+		log.Printf("Load state error: %v", err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	b.TotalUSDT = state.TotalUSDT
+	b.RealizedPNL = state.RealizedPNL
+	b.LastBuyPrice = state.LastBuyPrice
+	b.LastBuyTime = state.LastBuyTime
+	b.Started = state.Started
+	b.Records = state.Records
+	b.DealNumber = state.DealNumber
+	b.DealStep = state.DealStep
+	b.TPOrderID = state.TPOrderID
+	b.TPOrderPrice = state.TPOrderPrice
+	b.TPOrderQty = state.TPOrderQty
+	b.TPFilledQty = state.TPFilledQty
+	b.BasePrice = state.BasePrice
+	b.SafetyCount = state.SafetyCount
+	b.TrailingActive = state.TrailingActive
+	b.TrailingHigh = state.TrailingHigh
+	b.TrailingBuyKind = state.TrailingBuyKind
+	b.TrailingBuyLow = state.TrailingBuyLow
+	b.TrailingBuySince = state.TrailingBuySince
+	b.LotSells = state.LotSells
+	b.LotPNL = state.LotPNL
+	b.Regime = state.Regime
+	b.TPLevel = state.TPLevel
+	b.ProtectHit = state.ProtectHit
+	b.BuysPaused = state.BuysPaused
+	b.BuysPausedReason = state.BuysPausedReason
+	b.Paused = state.Paused
+	b.PauseReason = state.PauseReason
+	b.NextScheduledBuy = state.NextScheduledBuy
+	b.ValuePeriods = state.ValuePeriods
+	b.Deal = state.Deal
+	b.Deals = state.Deals
+	b.LastDealClosed = state.LastDealClosed
+	b.Vault = state.Vault
+	b.Reinvested = state.Reinvested
+	b.FundingPNL = state.FundingPNL
+	b.LastFundingTime = state.LastFundingTime
+	if state.InitialUSDT > 0 {
+		b.InitialUSDT = state.InitialUSDT
+	} else {
+		b.InitialUSDT = b.TotalUSDT + b.totalCost()/b.leverage() // compounding starts from here
+	}
+	if b.Deal == nil && len(b.Records) > 0 {
+		// State from before deals were tracked, adopt the running position
+		b.Deal = &Deal{
+			ID:          fmt.Sprintf("%s-%d", b.BotID, b.DealNumber),
+			Number:      b.DealNumber,
+			OpenedAt:    b.LastBuyTime,
+			Buys:        len(b.Records),
+			CapitalUSDT: b.totalCost(),
+		}
+	}
+	if state.Intents != nil {
+		b.Intents = state.Intents
+	}
+
+	fmt.Printf("♻️ Restored %s state: %d records, deal #%d, %d pending orders\n",
+		b.BotID, len(b.Records), b.DealNumber, len(b.Intents))
+
+	b.reconcileIntents(token)
+	b.checkTakeProfitFill(token)
+	b.saveState()
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Exchange-Native Take Profit
+////////////////////////////////////////////////////////////
+
+const (
+	tpCheckAtTarget = 5 * time.Second
+	tpCheckEvery    = time.Minute // wicks between trade ticks fill it too
+)
+
+// startOrderFeed has the exchange tell us about fills as they happen, where
+// it can, so the take profit is booked without waiting for the poll
+func (b *DCABot) startOrderFeed() {
+	if !b.NativeTakeProfit {
+		return
+	}
+	feed, ok := b.Exchange.(OrderStream)
+	if !ok {
+		return
+	}
+	go func() {
+		for {
+			err := feed.StreamOrders(func(o *Order) {
+				if o.FilledQty > 0 {
+					b.tpNotified.Store(true) // checkExits looks the order up
+				}
+			})
+			log.Printf("%s order WS disconnected: %v. Reconnecting...", b.Exchange.Name(), err)
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+// syncTakeProfit keeps a resting limit sell for the current holdings at the
+// deal's target price, amending it whenever a buy moves the average
+func (b *DCABot) syncTakeProfit(token string) {
+	if !b.NativeTakeProfit {
+		return
+	}
+
+	holdings := b.totalHoldings()
+	avg := b.avgBuyPrice()
+	if holdings == 0 || avg == 0 {
+		b.cancelTakeProfit(token)
+		return
+	}
+
+	if err := b.loadInstrument(); err != nil {
+		log.Printf("%s instrument error: %v", b.Exchange.Name(), err)
+		return
+	}
+
+	target, fraction := b.nextTakeProfit()
+	if target == 0 {
+		b.cancelTakeProfit(token)
+		return
+	}
+
+	qty := floorToStep(holdings*fraction, b.instrument.QtyStep)
+	price := ceilToStep(target, b.instrument.TickSize)
+	if parseStringToFloat(qty) < b.instrument.MinOrderQty {
+		b.cancelTakeProfit(token)
+		return
+	}
+
+	if b.TPOrderID != "" {
+		err := b.Exchange.AmendOrder(b.Symbol, b.TPOrderID, qty, price)
+		if err == nil {
+			b.TPOrderPrice = parseStringToFloat(price)
+			b.TPOrderQty = parseStringToFloat(qty)
+			log.Printf("Take profit amended → %s @ %s", qty, price)
+			b.saveState()
+			return
+		}
+
+		// The order may have filled or been cancelled on the exchange, or
+		// the exchange can't amend, so settle what we know about it and
+		// start over with a fresh one
+		if err != errAmendUnsupported {
+			log.Printf("TP Amend Error: %v", err)
+		}
+		b.cancelTakeProfit(token)
+		b.syncTakeProfit(token)
+		return
+	}
+
+	req := OrderRequest{Symbol: b.Symbol, Side: "Sell", Type: "Limit", Qty: qty, Price: price}
+	intent := &OrderIntent{LinkID: b.nextOrderLinkID("T"), Side: "Sell", TakeProfit: true, Qty: qty, Price: parseStringToFloat(price)}
+
+	order, err := b.submitOrder(intent, req)
+	if err != nil {
+		log.Printf("TP Place Error: %v", err)
+		b.handleOrderError("Take profit order", err, token)
+		return
+	}
+
+	b.TPOrderID = order.ID
+	b.TPOrderPrice = parseStringToFloat(price)
+	b.TPOrderQty = parseStringToFloat(qty)
+	b.TPFilledQty = 0
+	b.finishIntent(intent.LinkID)
+
+	message := fmt.Sprintf("🎯 TAKE PROFIT ORDER\nSymbol: %s\nQty: %s\nPrice: %s", b.Symbol, qty, price)
+	sendTelegramMessage(token, message)
+}
+
+// cancelTakeProfit removes the resting take-profit order, if any, and books
+// whatever filled before the cancel went through
+func (b *DCABot) cancelTakeProfit(token string) {
+	if b.TPOrderID == "" {
+		return
+	}
+
+	if err := b.Exchange.CancelOrder(b.Symbol, b.TPOrderID); err != nil {
+		log.Printf("TP Cancel Error: %v", err)
+	}
+	b.checkTakeProfitFill(token)
+
+	b.TPOrderID = ""
+	b.TPOrderPrice = 0
+	b.TPOrderQty = 0
+	b.TPFilledQty = 0
+	b.saveState()
+}
+
+// checkTakeProfitFill books any quantity the exchange filled on the
+// take-profit order since the last check
+func (b *DCABot) checkTakeProfitFill(token string) {
+	if b.TPOrderID == "" {
+		return
+	}
+	b.lastTPCheck = time.Now()
+
+	order, err := b.Exchange.GetOrder(b.Symbol, b.TPOrderID)
+	if err != nil {
+		log.Printf("TP Status Error: %v", err)
+		return
+	}
+
+	filled := order.FilledQty
+	if delta := filled - b.TPFilledQty; delta > 0 {
+		price := order.AvgPrice
+		if price == 0 {
+			price = b.TPOrderPrice
+		}
+		b.TPFilledQty = filled
+		realizedPNL := b.bookSell(price, delta)
+
+		message := fmt.Sprintf("🎯 %s TAKE PROFIT FILLED\nPrice: %.4f\nQty: %.6f\nRealized: %.2f\nStatus: %s",
+			strings.ToUpper(b.Exchange.Name()), price, delta, realizedPNL, order.Status)
+		sendTelegramMessage(token, message)
+	}
+
+	switch order.Status {
+	case OrderFilled:
+		b.TPOrderID = ""
+		b.TPOrderPrice = 0
+		b.TPOrderQty = 0
+		b.TPFilledQty = 0
+
+		level, last := b.advanceTPLevel()
+		if last {
+			b.Records = nil // deal closed, drop any dust below the lot step
+		}
+		if len(b.TPLadder) > 0 {
+			message := fmt.Sprintf("🎯 TP LEVEL %d/%d FILLED\nSymbol: %s\nLeft: %.6f", level, len(b.TPLadder), b.Symbol, b.totalHoldings())
+			sendTelegramMessage(token, message)
+		}
+	case OrderCancelled, OrderRejected:
+		b.TPOrderID = ""
+		b.TPFilledQty = 0
+	}
+	b.saveState()
+}
+
+func (b *DCABot) loadInstrument() error {
+	if b.instrument != nil {
+		return nil
+	}
+	instrument, err := b.Exchange.Instrument(b.Symbol)
+	if err != nil {
+		return err
+	}
+	b.instrument = instrument
+	return nil
+}
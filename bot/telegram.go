@@ -8,10 +8,16 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
+// telegramAPI is the Bot API host, tests point it at a local server
+var telegramAPI = "https://api.telegram.org"
+
 func sendTelegramMessage(token, message string) {
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
+	apiURL := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPI, token)
 
 	// Use a map for the JSON payload
 	payload := map[string]any{
@@ -41,3 +47,93 @@ func sendTelegramMessage(token, message string) {
 		log.Printf("Failed to send message: %s\nBody: %s\n", resp.Status, string(body))
 	}
 }
+
+var telegramPollClient = &http.Client{Timeout: 40 * time.Second}
+
+type telegramUpdate struct {
+	UpdateID int `json:"update_id"`
+	Message  struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Telegram answers a second getUpdates poller on a token with 409 Conflict,
+// so every bot on a token registers its commands with the one poller
+var (
+	telegramMu       sync.Mutex
+	telegramCommands = map[string][]map[string]func() string{}
+)
+
+// listenTelegramCommands answers commands such as /status sent to the bot
+// from the configured chat, until the process exits. The first bot on a
+// token runs the poller, later ones only add their commands to it.
+func listenTelegramCommands(token string, commands map[string]func() string) {
+	if token == "" {
+		return
+	}
+
+	telegramMu.Lock()
+	telegramCommands[token] = append(telegramCommands[token], commands)
+	first := len(telegramCommands[token]) == 1
+	telegramMu.Unlock()
+	if !first {
+		return
+	}
+
+	offset := 0
+	for {
+		updates, err := getTelegramUpdates(token, offset)
+		if err != nil {
+			log.Println("Telegram updates error:", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if fmt.Sprint(u.Message.Chat.ID) != config.TelegramChatId {
+				continue
+			}
+			fields := strings.Fields(u.Message.Text)
+			if len(fields) == 0 {
+				continue
+			}
+			// Commands in groups arrive as /status@botname
+			command, _, _ := strings.Cut(fields[0], "@")
+			telegramMu.Lock()
+			bots := telegramCommands[token]
+			telegramMu.Unlock()
+			for _, commands := range bots {
+				if handler, ok := commands[command]; ok {
+					sendTelegramMessage(token, handler())
+				}
+			}
+		}
+	}
+}
+
+func getTelegramUpdates(token string, offset int) ([]telegramUpdate, error) {
+	apiURL := fmt.Sprintf("%s/bot%s/getUpdates?timeout=30&offset=%d", telegramAPI, token, offset)
+
+	resp, err := telegramPollClient.Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		OK          bool             `json:"ok"`
+		Description string           `json:"description"`
+		Result      []telegramUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.OK {
+		return nil, fmt.Errorf("telegram: %s", data.Description)
+	}
+	return data.Result, nil
+}
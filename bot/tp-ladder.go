@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////
+// Take Profit Ladder
+////////////////////////////////////////////////////////////
+
+// TPLevel sells Share percent of the deal once the price is Profit percent
+// above the average entry. The last level always sells what is left.
+type TPLevel struct {
+	Share  float64
+	Profit float64
+}
+
+// ParseTPLadder reads levels written as share@profit, e.g. "30@2, 30@4,
+// rest@7". Shares are percent of the position and add up to 100, "rest" is
+// only allowed last.
+func ParseTPLadder(s string) ([]TPLevel, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	var levels []TPLevel
+	total := 0.0
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		shareStr, profitStr, ok := strings.Cut(strings.TrimSpace(part), "@")
+		if !ok {
+			return nil, fmt.Errorf("level %q: use share@profit, e.g. 30@2", part)
+		}
+
+		profit, err := strconv.ParseFloat(strings.TrimSpace(profitStr), 64)
+		if err != nil || profit <= 0 {
+			return nil, fmt.Errorf("level %q: bad profit percent", part)
+		}
+		if len(levels) > 0 && profit <= levels[len(levels)-1].Profit {
+			return nil, fmt.Errorf("level %q: profits must go up level by level", part)
+		}
+
+		shareStr = strings.TrimSpace(shareStr)
+		last := i == len(parts)-1
+		var share float64
+		if strings.EqualFold(shareStr, "rest") {
+			if !last {
+				return nil, fmt.Errorf("level %q: only the last level can sell the rest", part)
+			}
+			share = 100 - total
+			if share <= 1e-9 {
+				return nil, fmt.Errorf("level %q: the shares before it already sell everything", part)
+			}
+		} else if share, err = strconv.ParseFloat(shareStr, 64); err != nil || share <= 0 {
+			return nil, fmt.Errorf("level %q: bad share percent", part)
+		}
+
+		total += share
+		if total > 100+1e-9 {
+			return nil, fmt.Errorf("shares add up to more than 100%%")
+		}
+		levels = append(levels, TPLevel{Share: share, Profit: profit})
+	}
+	// The last level sells what is left, so a short ladder would not do what
+	// it says
+	if total < 100-1e-9 {
+		return nil, fmt.Errorf("shares add up to %g%%, make them 100%% or end with rest@profit", total)
+	}
+	return levels, nil
+}
+
+// ladder is the configured ladder or, without one, a single level selling
+// everything at the SellPercent target
+func (b *DCABot) ladder() []TPLevel {
+	if len(b.TPLadder) > 0 {
+		return b.TPLadder
+	}
+	return []TPLevel{{Share: 100, Profit: b.sellStep()}}
+}
+
+// nextTakeProfit is the target price and the fraction of current holdings
+// the next exit sells, 0 once every level of the deal is done. Short targets
+// sit below the average.
+func (b *DCABot) nextTakeProfit() (target, fraction float64) {
+	ladder := b.ladder()
+	if b.TPLevel >= len(ladder) {
+		return 0, 0
+	}
+
+	avg := b.avgBuyPrice()
+	level := ladder[b.TPLevel]
+	if b.TPLevel == len(ladder)-1 {
+		return avg * (1 + b.dir()*level.Profit/100), 1
+	}
+
+	// Shares are of the whole deal, so scale by what earlier levels left
+	remaining := 0.0
+	for _, l := range ladder[b.TPLevel:] {
+		remaining += l.Share
+	}
+	return avg * (1 + b.dir()*level.Profit/100), level.Share / remaining
+}
+
+// advanceTPLevel marks the current level as done after its sell filled and
+// reports whether that was the last one
+func (b *DCABot) advanceTPLevel() (level int, last bool) {
+	b.TPLevel++
+	return b.TPLevel, b.TPLevel >= len(b.ladder())
+}
+
+// ladderStatus is the ladder line for /status and the alerts
+func (b *DCABot) ladderStatus() string {
+	if len(b.TPLadder) == 0 {
+		return ""
+	}
+	if b.TPLevel >= len(b.TPLadder) {
+		return fmt.Sprintf("TP Ladder: all %d levels done", len(b.TPLadder))
+	}
+	target, _ := b.nextTakeProfit()
+	level := b.TPLadder[b.TPLevel]
+	return fmt.Sprintf("TP Ladder: level %d/%d next, %.0f%% at +%.2f%% (%.4f)",
+		b.TPLevel+1, len(b.TPLadder), level.Share, level.Profit, target)
+}
+
+// takeProfit sells the next exit at market, the ladder moves on once the
+// whole sell is booked
+func (b *DCABot) takeProfit(price, fraction float64, token string) {
+	b.executeSell(price, fraction, true, token, func() { b.tpLevelSold(token) })
+}
+
+// tpLevelSold marks the level done and closes the deal after the last one
+func (b *DCABot) tpLevelSold(token string) {
+	level, last := b.advanceTPLevel()
+	if last {
+		b.Records = nil // deal closed, drop any dust below the lot step
+	}
+	b.saveState()
+	if len(b.TPLadder) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("🎯 TP LEVEL %d/%d HIT\nSymbol: %s\nLeft: %.6f", level, len(b.TPLadder), b.Symbol, b.totalHoldings())
+	if !last {
+		next := b.TPLadder[b.TPLevel]
+		message += fmt.Sprintf("\nNext: %.0f%% at +%.2f%%", next.Share, next.Profit)
+	}
+	sendTelegramMessage(token, message)
+}
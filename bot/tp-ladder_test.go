@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTPLadder(t *testing.T) {
+	levels, err := ParseTPLadder("30@2, 30@4, rest@7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 3 || levels[2].Share != 40 || levels[2].Profit != 7 {
+		t.Errorf("levels %+v", levels)
+	}
+
+	if _, err := ParseTPLadder("50@2, 50@4"); err != nil {
+		t.Errorf("shares of 100%%: %v", err)
+	}
+
+	for _, bad := range []string{
+		"30@2, 30@4",    // 40% never sold at its own level
+		"50@2, 60@4",    // over 100%
+		"rest@2, 50@4",  // rest not last
+		"50@4, 50@2",    // profits going down
+		"100@2, rest@4", // nothing left for rest
+		"30",            // no profit
+	} {
+		if _, err := ParseTPLadder(bad); err == nil {
+			t.Errorf("ParseTPLadder(%q) accepted", bad)
+		}
+	}
+}
+
+func TestDefaultTakeProfitFiresOnce(t *testing.T) {
+	b := &DCABot{SellPercent: 2, Records: []DCARecord{{Price: 100, USDTSpent: 100, AmountBought: 1}}}
+
+	target, fraction := b.nextTakeProfit()
+	if math.Abs(target-102) > 1e-9 || fraction != 1 {
+		t.Fatalf("default exit %.4f x %v, want 102 x 1", target, fraction)
+	}
+	if level, last := b.advanceTPLevel(); level != 1 || !last {
+		t.Errorf("advance %d %v", level, last)
+	}
+	if target, _ := b.nextTakeProfit(); target != 0 {
+		t.Errorf("default exit fires again at %.4f", target)
+	}
+
+	b.TPLadder, _ = ParseTPLadder("50@2, rest@4")
+	b.TPLevel = 0
+	if _, fraction := b.nextTakeProfit(); fraction != 0.5 {
+		t.Errorf("first level sells %v", fraction)
+	}
+	b.advanceTPLevel()
+	if target, fraction := b.nextTakeProfit(); math.Abs(target-104) > 1e-9 || fraction != 1 {
+		t.Errorf("last level %.4f x %v", target, fraction)
+	}
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Trailing Buy
+////////////////////////////////////////////////////////////
+
+// trailingBuyEnabled reports whether triggered buys wait for a bounce instead
+// of buying into the fall
+func (b *DCABot) trailingBuyEnabled() bool {
+	return b.TrailingBuyRebound > 0
+}
+
+// triggerBuy places a buy whose trigger just fired, or arms the trailing
+// buy and reports false when it has to wait for a rebound first
+func (b *DCABot) triggerBuy(kind string, price float64, token string) bool {
+	if !b.trailingBuyEnabled() {
+		return b.buyNow(price, token)
+	}
+
+	b.TrailingBuyKind = kind
+	b.TrailingBuyLow = price
+	b.TrailingBuySince = time.Now()
+	b.saveState()
+
+	message := fmt.Sprintf("🪃 TRAILING BUY ARMED\nSymbol: %s\nOrder: %s\nPrice: %.4f\nBuys on a %.2f%% rebound from the low",
+		b.Symbol, kind, price, b.TrailingBuyRebound)
+	if b.TrailingBuyMaxWait > 0 {
+		message += fmt.Sprintf(" or after %s", formatDuration(b.TrailingBuyMaxWait))
+	}
+	sendTelegramMessage(token, message)
+	return false
+}
+
+// checkTrailingBuy follows the low of an armed trailing buy and buys once the
+// price bounces TrailingBuyRebound off it or the wait runs out
+func (b *DCABot) checkTrailingBuy(price float64, token string) bool {
+	if b.adverseMove(b.TrailingBuyLow, price) > 0 {
+		b.TrailingBuyLow = price // for shorts the "low" is the high
+		if time.Since(b.lastTrailingSave) >= 5*time.Second {
+			b.lastTrailingSave = time.Now()
+			b.saveState()
+		}
+	}
+
+	rebound := -b.adverseMove(b.TrailingBuyLow, price)
+	waited := time.Since(b.TrailingBuySince)
+	var reason string
+	switch {
+	case rebound >= b.TrailingBuyRebound:
+		reason = fmt.Sprintf("Rebound: %.2f%% from %.4f", rebound, b.TrailingBuyLow)
+	case b.TrailingBuyMaxWait > 0 && waited >= b.TrailingBuyMaxWait:
+		reason = fmt.Sprintf("Max wait of %s reached, low %.4f", formatDuration(b.TrailingBuyMaxWait), b.TrailingBuyLow)
+	default:
+		return false
+	}
+
+	kind := b.TrailingBuyKind
+	if !b.buyNow(price, token) {
+		return false // stays armed, a held or refused buy is tried again
+	}
+	fmt.Printf("TRAILING BUY → %s at %.4f after %s\n", kind, price, formatDuration(waited))
+	message := fmt.Sprintf("🪃 TRAILING BUY TRIGGERED\nSymbol: %s\nOrder: %s\nPrice: %.4f\n%s",
+		b.Symbol, kind, price, reason)
+	sendTelegramMessage(token, message)
+
+	b.resetTrailingBuy()
+	return true
+}
+
+// buyNow places the next order of the deal at market and reports whether it
+// went out. A refused buy leaves the last buy as it was, so it triggers again.
+func (b *DCABot) buyNow(price float64, token string) bool {
+	if !b.executeBuy(price, b.nextOrderUSDT(), token) {
+		return false
+	}
+	b.LastBuyPrice = price
+	b.LastBuyTime = time.Now()
+	b.Started = true
+	b.saveState()
+	return true
+}
+
+func (b *DCABot) resetTrailingBuy() {
+	if b.TrailingBuyKind == "" {
+		return
+	}
+	b.TrailingBuyKind = ""
+	b.TrailingBuyLow = 0
+	b.TrailingBuySince = time.Time{}
+	b.saveState()
+}
+
+// trailingBuyStatus is the trailing buy line for /status
+func (b *DCABot) trailingBuyStatus() string {
+	if !b.trailingBuyEnabled() {
+		return ""
+	}
+	if b.TrailingBuyKind == "" {
+		return fmt.Sprintf("Trailing buy: waiting for a trigger (%.2f%% rebound)", b.TrailingBuyRebound)
+	}
+	return fmt.Sprintf("Trailing buy: %s armed %s ago, low %.4f, buys at %.4f",
+		b.TrailingBuyKind, formatDuration(time.Since(b.TrailingBuySince)), b.TrailingBuyLow,
+		b.TrailingBuyLow*(1+b.dir()*b.TrailingBuyRebound/100))
+}
@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Trailing Take Profit
+////////////////////////////////////////////////////////////
+
+// trailingEnabled reports whether exits trail the price instead of selling
+// at the first touch of the target
+func (b *DCABot) trailingEnabled() bool {
+	return b.TrailingDeviation > 0
+}
+
+// checkTrailingExit arms the trail once the price reaches the target, then
+// follows the high and sells when the price falls TrailingDeviation below it
+func (b *DCABot) checkTrailingExit(price, target, fraction float64, token string) {
+	if !b.TrailingActive {
+		if price < target {
+			return
+		}
+		b.TrailingActive = true
+		b.TrailingHigh = price
+		b.saveState()
+
+		message := fmt.Sprintf("🎢 TRAILING TP ARMED\nSymbol: %s\nPrice: %.4f\nTarget: %.4f\nSells on a %.2f%% pullback from the high",
+			b.Symbol, price, target, b.TrailingDeviation)
+		sendTelegramMessage(token, message)
+		return
+	}
+
+	if price > b.TrailingHigh {
+		b.TrailingHigh = price
+		// A strong move makes a new high on every tick, save it at most every few seconds
+		if time.Since(b.lastTrailingSave) >= 5*time.Second {
+			b.lastTrailingSave = time.Now()
+			b.saveState()
+		}
+		return
+	}
+
+	stop := b.trailingStop()
+	if price > stop {
+		return
+	}
+
+	fmt.Printf("TRAILING SELL → Price %.4f ≤ Stop %.4f (High %.4f)\n", price, stop, b.TrailingHigh)
+	message := fmt.Sprintf("🎢 TRAILING TP TRIGGERED\nSymbol: %s\nHigh: %.4f\nPrice: %.4f\nPullback: %.2f%%",
+		b.Symbol, b.TrailingHigh, price, (1-price/b.TrailingHigh)*100)
+	sendTelegramMessage(token, message)
+
+	b.resetTrailing()
+	b.takeProfit(price, fraction, token)
+}
+
+func (b *DCABot) trailingStop() float64 {
+	return b.TrailingHigh * (1 - b.TrailingDeviation/100)
+}
+
+func (b *DCABot) resetTrailing() {
+	if !b.TrailingActive && b.TrailingHigh == 0 {
+		return
+	}
+	b.TrailingActive = false
+	b.TrailingHigh = 0
+	b.saveState()
+}
+
+// trailingStatus is the trailing line for /status and the daily report
+func (b *DCABot) trailingStatus() string {
+	if !b.trailingEnabled() {
+		return ""
+	}
+	if !b.TrailingActive {
+		return fmt.Sprintf("Trailing TP: waiting for target (%.2f%% pullback)", b.TrailingDeviation)
+	}
+	return fmt.Sprintf("Trailing TP: armed, high %.4f, sells at %.4f", b.TrailingHigh, b.trailingStop())
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+////////////////////////////////////////////////////////////
+// Value Averaging
+////////////////////////////////////////////////////////////
+
+// valueAveraging reports whether scheduled slots trade towards a target
+// value path instead of buying a fixed amount
+func (b *DCABot) valueAveraging() bool {
+	return b.Schedule != nil && b.ValueStepUSDT > 0
+}
+
+// targetValue is where the position should stand after the slots so far.
+// Missed slots count too, the caps spread the catch-up over later slots.
+func (b *DCABot) targetValue() float64 {
+	return b.ValueStepUSDT * float64(b.ValuePeriods)
+}
+
+// valueAverage buys or sells the gap between the position's value and the
+// target, each trade capped by MaxBuyUSDT / MaxSellUSDT
+func (b *DCABot) valueAverage(slots int, price float64, token string) {
+	b.ValuePeriods += slots
+	b.saveState()
+
+	target := b.targetValue()
+	value := b.totalHoldings() * price
+	gap := target - value
+	fmt.Printf("VALUE AVERAGING → slot %d, target %.2f, value %.2f, gap %.2f USDT\n", b.ValuePeriods, target, value, gap)
+
+	minTrade := 1.0
+	if err := b.loadInstrument(); err == nil {
+		minTrade = math.Max(minTrade, b.instrument.MinOrderAmt)
+	}
+
+	switch {
+	case math.Abs(gap) < minTrade:
+		log.Printf("%s value averaging: on target (gap %.2f USDT)", b.Symbol, gap)
+
+	case gap > 0:
+		usdt := gap
+		if b.MaxBuyUSDT > 0 {
+			usdt = math.Min(usdt, b.MaxBuyUSDT)
+		}
+		// A gap over the budget buys what is left, executeBuy refuses more
+		usdt = math.Min(usdt, b.TotalUSDT)
+		if usdt < minTrade {
+			log.Printf("%s value averaging: %.2f USDT budget left, below the %.2f USDT minimum", b.Symbol, b.TotalUSDT, minTrade)
+			break
+		}
+		if b.executeBuy(price, usdt, token) {
+			b.LastBuyPrice = price
+			b.LastBuyTime = time.Now()
+			b.Started = true
+		}
+
+	case b.MaxSellUSDT > 0:
+		usdt := math.Min(-gap, b.MaxSellUSDT)
+		b.executeSell(price, math.Min(usdt/value, 1), true, token, nil)
+
+	default:
+		log.Printf("%s value averaging: %.2f USDT above target, selling is off", b.Symbol, -gap)
+	}
+	b.saveState()
+
+	pnlUSDT, pnlPercent := b.UnrealizedPNL(price)
+	message := fmt.Sprintf("📐 %s VALUE AVERAGING\nSlot: %d\nTarget: %.2f USDT\nValue: %.2f USDT\nUnrealized PNL: %.2f USDT (%.2f%%)\nNext: %s",
+		b.Symbol, b.ValuePeriods, target, b.totalHoldings()*price, pnlUSDT, pnlPercent, b.NextScheduledBuy.Format("2006-01-02 15:04"))
+	sendTelegramMessage(token, message)
+}
+
+// valueStatus is the value path line for /status and the daily report
+func (b *DCABot) valueStatus() string {
+	if !b.valueAveraging() {
+		return ""
+	}
+	return fmt.Sprintf("Value path: +%.2f USDT %s, slot %d, target %.2f, value %.2f, next %s",
+		b.ValueStepUSDT, b.Schedule, b.ValuePeriods, b.targetValue(), b.totalHoldings()*b.LatestDayPrice,
+		b.NextScheduledBuy.Format("2006-01-02 15:04"))
+}
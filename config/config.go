@@ -26,14 +26,18 @@ var (
 	SOL1_1h          string
 	BybitApiKey      string
 	BybitApiSecret   string
+	OKXApiKey        string
+	OKXApiSecret     string
+	OKXPassphrase    string
 )
 
 // LoadConfig
 func LoadConfig() {
 	_ = godotenv.Load()
 
-	BinanceApiKey = GetEnv("BINANCE_API_KEY")
-	BinanceApiSecret = GetEnv("BINANCE_API_SECRET")
+	// Exchange keys are optional here, a bot checks the ones it trades with
+	BinanceApiKey = os.Getenv("BINANCE_API_KEY")
+	BinanceApiSecret = os.Getenv("BINANCE_API_SECRET")
 	BTC2 = GetEnv("BTC2")
 	BTC1 = GetEnv("BTC1")
 	BTC1x5 = GetEnv("BTC1x5")
@@ -48,8 +52,11 @@ func LoadConfig() {
 	ADA1_1h = GetEnv("ADA1_1h")
 	BNB1_1h = GetEnv("BNB1_1h")
 	SOL1_1h = GetEnv("SOL1_1h")
-	BybitApiKey = GetEnv("BYBIT_API_KEY")
-	BybitApiSecret = GetEnv("BYBIT_API_SECRET")
+	BybitApiKey = os.Getenv("BYBIT_API_KEY")
+	BybitApiSecret = os.Getenv("BYBIT_API_SECRET")
+	OKXApiKey = os.Getenv("OKX_API_KEY")
+	OKXApiSecret = os.Getenv("OKX_API_SECRET")
+	OKXPassphrase = os.Getenv("OKX_PASSPHRASE")
 }
 
 func GetEnv(key string) string {
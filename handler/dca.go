@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bufio"
+	"dca-bot/bot"
 	"dca-bot/service"
 	"fmt"
 	"os"
@@ -9,6 +10,21 @@ import (
 	"strings"
 )
 
+// stdin is shared by every prompt, a second buffered reader on os.Stdin
+// would swallow answers typed ahead or piped in
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadStrategy asks which bot to run, dca when left empty
+func ReadStrategy() string {
+	fmt.Print("Strategy (dca, signal, grid) [dca]: ")
+	strategy, _ := stdin.ReadString('\n')
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" {
+		return "dca"
+	}
+	return strategy
+}
+
 type DCAHandler struct {
 	service *service.DCAService
 }
@@ -19,42 +35,267 @@ func NewDCAHandler() *DCAHandler {
 	}
 }
 
+// StartDCA asks for the DCA setup, connects to the exchange and starts the
+// bot in the background
 func (h *DCAHandler) StartDCA() error {
-	reader := bufio.NewReader(os.Stdin)
+	reader := stdin
 
-	fmt.Printf("Enter trading pair (e.g. btcusdt): ")
+	fmt.Print("Enter trading pair (e.g. BTCUSDT): ")
 	symbol, _ := reader.ReadString('\n')
-	symbol = strings.TrimSpace(symbol)
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
 
-	fmt.Printf("Enter total USDT amount for DCA: ")
-	usdtStr, _ := reader.ReadString('\n')
-	usdtStr = strings.TrimSpace(usdtStr)
-	totalUsdt, err := strconv.ParseFloat(usdtStr, 64)
-	if err != nil {
-		return fmt.Errorf("invalid USDT amount")
+	fmt.Print("Exchange (bybit, binance, okx) [bybit]: ")
+	exchangeName, _ := reader.ReadString('\n')
+	exchangeName = strings.TrimSpace(exchangeName)
+	if exchangeName == "" {
+		exchangeName = "bybit"
+	}
+	if err := bot.CheckAPIKeys(exchangeName); err != nil {
+		return err
 	}
 
-	fmt.Printf("Enter drop percentage to trigger buy (e.g. 2): ")
-	dropStr, _ := reader.ReadString('\n')
-	dropStr = strings.TrimSpace(dropStr)
-	dropPercent, err := strconv.ParseFloat(dropStr, 64)
+	env, err := readEnvironment(reader)
 	if err != nil {
-		return fmt.Errorf("invalid drop percentage")
+		return err
+	}
+
+	fmt.Print("Side (long, short) [long]: ")
+	side, _ := reader.ReadString('\n')
+	short := strings.EqualFold(strings.TrimSpace(side), "short")
+
+	cfg := bot.DCAConfig{
+		Environment: env.Name,
+		Symbol:      symbol,
+		Short:       short,
+	}
+	var exchange bot.SpotExchange
+	var account bot.AccountService
+	if short {
+		if exchange, _, account, err = bot.NewFuturesExchange(exchangeName, env); err != nil {
+			return err
+		}
+		if cfg.Leverage = readNumber(reader, "Leverage [1]: ", 1); cfg.Leverage <= 0 {
+			cfg.Leverage = 1
+		}
+		fmt.Print("Margin mode (keep, cross, isolated) [keep]: ")
+		margin, _ := reader.ReadString('\n')
+		if cfg.MarginMode, err = bot.ParseMarginMode(margin); err != nil {
+			return err
+		}
+		cfg.LiqAlertPercent = readNumber(reader, "Alert when liquidation is within % of the mark price (0 = off) [10]: ", 10)
+	} else if exchange, account, err = bot.NewSpotExchange(exchangeName, env); err != nil {
+		return err
 	}
 
-	fmt.Print("Fallback Buy Again: ")
-	fallbackBuyHoursInput, _ := reader.ReadString('\n')
-	fallbackBuyHoursStr := strings.TrimSpace(fallbackBuyHoursInput)
+	if err := account.Refresh(); err != nil {
+		return fmt.Errorf("fetch balance: %w", err)
+	}
+	go bot.RunAccountRefresh(account)
 
-	fallbackBuyHours, _ := strconv.ParseInt(fallbackBuyHoursStr, 10, 64)
+	balance := account.Balance("USDT").Free
+	if balance <= 0 {
+		return fmt.Errorf("no USDT balance found, check that the funds are in your Unified/Spot account")
+	}
+	fmt.Printf("✅ Balance found: %.2f USDT\n", balance)
+	cfg.TotalUSDT = readNumber(reader, fmt.Sprintf("Total USDT for DCA [%.2f]: ", balance), balance)
+
+	fmt.Print("Buy schedule (cron like '0 9 * * 1' or 'every 24h'; empty = buy on drops): ")
+	scheduleStr, _ := reader.ReadString('\n')
+	if cfg.Schedule, err = bot.ParseSchedule(scheduleStr); err != nil {
+		return err
+	}
+	schedule := cfg.Schedule
 
-	fmt.Printf("Enter sell percentage(e.g. 2): ")
+	if schedule == nil {
+		fmt.Print("Enter drop percentage trigger (e.g. 1.5): ")
+		dropStr, _ := reader.ReadString('\n')
+		if cfg.DropPercent, err = strconv.ParseFloat(strings.TrimSpace(dropStr), 64); err != nil {
+			return fmt.Errorf("invalid drop percentage")
+		}
+		cfg.FallbackBuyHours = int(readNumber(reader, "Fallback buy hours [24]: ", 24))
+	}
+
+	fmt.Print("Enter sell percentage (e.g. 1.5): ")
 	sellStr, _ := reader.ReadString('\n')
-	sellStr = strings.TrimSpace(sellStr)
-	sellPercent, err := strconv.ParseFloat(sellStr, 64)
+	if cfg.SellPercent, err = strconv.ParseFloat(strings.TrimSpace(sellStr), 64); err != nil {
+		return fmt.Errorf("invalid sell percentage")
+	}
+
+	fmt.Printf("Keep take profit order on %s? (y/N): ", exchange.Name())
+	tp, _ := reader.ReadString('\n')
+	cfg.NativeTakeProfit = strings.EqualFold(strings.TrimSpace(tp), "y")
+
+	if schedule != nil {
+		cfg.ValueStepUSDT = readNumber(reader, "Value averaging, grow the target value by USDT per slot (0 = fixed buys) [0]: ", 0)
+	}
+	if cfg.ValueStepUSDT > 0 {
+		cfg.MaxBuyUSDT = readNumber(reader, "Max USDT per buy (0 = no cap) [0]: ", 0)
+		cfg.MaxSellUSDT = readNumber(reader, "Max USDT per sell (0 = never sell) [0]: ", 0)
+	} else if schedule != nil {
+		cfg.ScheduleUSDT = readNumber(reader, "USDT per scheduled buy [50]: ", 50)
+		cfg.BoostPercent = readNumber(reader, "Boost when price is % under the 30-day average (0 = off) [0]: ", 0)
+		if cfg.BoostPercent > 0 {
+			cfg.BoostMultiplier = readNumber(reader, "Boost multiplier [2]: ", 2)
+		}
+		fmt.Print("Missed buys after downtime (skip, once, all) [once]: ")
+		catchUp, _ := reader.ReadString('\n')
+		if cfg.CatchUp, err = bot.ParseCatchUp(catchUp); err != nil {
+			return err
+		}
+	} else {
+		cfg.BaseOrderUSDT = readNumber(reader, "Base order USDT [1]: ", 1)
+		cfg.MaxSafetyOrders = int(readNumber(reader, "Max safety orders (0 = flat buys on every drop) [0]: ", 0))
+		if cfg.MaxSafetyOrders > 0 {
+			cfg.SafetyOrderUSDT = readNumber(reader, "Safety order USDT: ", 0)
+			cfg.VolumeScale = readNumber(reader, "Volume scale [1]: ", 1)
+			cfg.StepScale = readNumber(reader, "Step scale [1]: ", 1)
+		}
+	}
+	cfg.TrailingBuyRebound = readNumber(reader, "Trailing buy, wait for a % rebound off the low before buying (0 = off) [0]: ", 0)
+	if cfg.TrailingBuyRebound > 0 {
+		cfg.TrailingBuyMaxWaitMinutes = int(readNumber(reader, "Max minutes to wait for the rebound (0 = no limit) [60]: ", 60))
+	}
+	fmt.Print("ATR timeframe for adaptive steps (e.g. 4h; empty = fixed %): ")
+	atrInterval, _ := reader.ReadString('\n')
+	if cfg.ATRStep.Interval = strings.TrimSpace(atrInterval); cfg.ATRStep.Interval != "" {
+		cfg.ATRStep.Period = int(readNumber(reader, "ATR period [14]: ", 14))
+		cfg.ATRStep.DropMultiplier = readNumber(reader, "Buy after a move of k x ATR, k (0 = fixed drop %) [1]: ", 1)
+		cfg.ATRStep.SellMultiplier = readNumber(reader, "Sell k x ATR above the average, k (0 = fixed sell %) [0]: ", 0)
+	}
+	fmt.Print("Take profit on the average or lot by lot (average, lot) [average]: ")
+	exitMode, _ := reader.ReadString('\n')
+	if cfg.ExitMode, err = bot.ParseExitMode(exitMode); err != nil {
+		return err
+	}
+	if cfg.ExitMode == bot.ExitAverage {
+		fmt.Print("Cost basis for realized PNL (fifo, lifo, hifo, average) [fifo]: ")
+		basis, _ := reader.ReadString('\n')
+		if cfg.CostBasis, err = bot.ParseCostBasis(basis); err != nil {
+			return err
+		}
+		cfg.TrailingDeviation = readNumber(reader, "Trailing take profit deviation % (0 = off) [0]: ", 0)
+
+		fmt.Print("Take profit ladder, share@profit (e.g. 30@2, 30@4, rest@7; empty = all at sell %): ")
+		ladderStr, _ := reader.ReadString('\n')
+		if cfg.TPLadder, err = bot.ParseTPLadder(ladderStr); err != nil {
+			return err
+		}
+	}
+
+	cfg.StopLossPercent = readNumber(reader, "Stop loss % below average (0 = off) [0]: ", 0)
+	if cfg.StopLossAction, err = readProtectAction(reader, "Stop loss", cfg.StopLossPercent); err != nil {
+		return err
+	}
+	cfg.MaxDrawdownUSDT = readNumber(reader, "Max unrealized drawdown USDT (0 = off) [0]: ", 0)
+	if cfg.DrawdownAction, err = readProtectAction(reader, "Drawdown", cfg.MaxDrawdownUSDT); err != nil {
+		return err
+	}
+	cfg.FloorPrice = readNumber(reader, "Stop buying below price (0 = off) [0]: ", 0)
+	if cfg.FloorAction, err = readProtectAction(reader, "Floor", cfg.FloorPrice); err != nil {
+		return err
+	}
+
+	cfg.DealCooldownMinutes = int(readNumber(reader, "Cooldown minutes between deals (0 = restart right away) [0]: ", 0))
+	fmt.Print("Pause after each deal until /resume? (y/N): ")
+	stop, _ := reader.ReadString('\n')
+	cfg.StopAfterDeal = strings.EqualFold(strings.TrimSpace(stop), "y")
+
+	fmt.Print("Reinvest profits (fixed, compound, vault) [fixed]: ")
+	reinvest, _ := reader.ReadString('\n')
+	if cfg.Reinvest, err = bot.ParseReinvestPolicy(reinvest); err != nil {
+		return err
+	}
+	if cfg.Reinvest == bot.ReinvestVault {
+		cfg.VaultPercent = readNumber(reader, "Percent of each profit to lock in the vault [50]: ", 50)
+	}
+
+	fmt.Print("Entry filter timeframe (e.g. 1h; empty = no filters): ")
+	interval, _ := reader.ReadString('\n')
+	if cfg.EntryFilter.Interval = strings.TrimSpace(interval); cfg.EntryFilter.Interval != "" {
+		cfg.EntryFilter.RSIBelow = readNumber(reader, "Buy only when RSI is below (0 = off) [0]: ", 0)
+		fmt.Print("Buy only when the close is under the lower Bollinger band? (y/N): ")
+		bb, _ := reader.ReadString('\n')
+		cfg.EntryFilter.BollingerLow = strings.EqualFold(strings.TrimSpace(bb), "y")
+		cfg.EntryFilter.EMALength = int(readNumber(reader, "EMA length (0 = off) [0]: ", 0))
+		if cfg.EntryFilter.EMALength > 0 {
+			fmt.Print("Buy only when the price is above or below the EMA (above/below) [above]: ")
+			side, _ := reader.ReadString('\n')
+			if cfg.EntryFilter.EMASide, err = bot.ParseEMASide(side); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Print("Adapt buying to the bull/range/bear regime (daily 200 EMA, weekly trend, volatility)? (y/N): ")
+	regime, _ := reader.ReadString('\n')
+	if cfg.RegimeFilter.Enabled = strings.EqualFold(strings.TrimSpace(regime), "y"); cfg.RegimeFilter.Enabled {
+		cfg.RegimeFilter.Bull = readRegimeRule(reader, "Bull", false, 1, 1)
+		cfg.RegimeFilter.Range = readRegimeRule(reader, "Range", false, 1, 1)
+		cfg.RegimeFilter.Bear = readRegimeRule(reader, "Bear", true, 2, 0.5)
+	}
+
+	cfg.BookGuard.MaxSpread = readNumber(reader, "Max spread % for market orders (0 = off) [0]: ", 0)
+	cfg.BookGuard.MaxSlippage = readNumber(reader, "Max estimated slippage % for market orders (0 = off) [0]: ", 0)
+	if cfg.BookGuard.Enabled() {
+		fmt.Print("When the book can't take an order (delay, split, limit) [delay]: ")
+		action, _ := reader.ReadString('\n')
+		if cfg.BookGuard.Action, err = bot.ParseBookAction(action); err != nil {
+			return err
+		}
+	}
+
+	return h.service.Start(exchange, account, cfg)
+}
+
+// readNumber asks for a number, falling back to def on empty or bad input
+func readNumber(reader *bufio.Reader, prompt string, def float64) float64 {
+	fmt.Print(prompt)
+	input, _ := reader.ReadString('\n')
+	v, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
 	if err != nil {
-		return fmt.Errorf("invalid drop percentage")
+		return def
 	}
+	return v
+}
 
-	return h.service.Start(symbol, totalUsdt, dropPercent, sellPercent, int(fallbackBuyHours))
+// readRegimeRule asks how buying changes in a regime
+func readRegimeRule(reader *bufio.Reader, name string, pause bool, dropScale, sizeScale float64) bot.RegimeRule {
+	def := "N"
+	if pause {
+		def = "Y"
+	}
+	fmt.Printf("%s regime: pause buys? (y/n) [%s]: ", name, def)
+	answer, _ := reader.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		pause = strings.EqualFold(answer, "y")
+	}
+	if pause {
+		return bot.RegimeRule{Pause: true}
+	}
+	return bot.RegimeRule{
+		DropScale: readNumber(reader, fmt.Sprintf("%s regime drop spacing multiplier [%g]: ", name, dropScale), dropScale),
+		SizeScale: readNumber(reader, fmt.Sprintf("%s regime order size multiplier [%g]: ", name, sizeScale), sizeScale),
+	}
+}
+
+// readProtectAction asks what a protection does, only when it is switched on
+func readProtectAction(reader *bufio.Reader, name string, value float64) (bot.ProtectAction, error) {
+	if value <= 0 {
+		return "", nil
+	}
+	fmt.Printf("%s action (sell, pause, alert) [pause]: ", name)
+	input, _ := reader.ReadString('\n')
+	return bot.ParseProtectAction(input)
+}
+
+// readEnvironment asks which hosts to use, defaulting to tr where the bot
+// has always traded
+func readEnvironment(reader *bufio.Reader) (bot.Environment, error) {
+	fmt.Printf("Environment (%s) [tr]: ", bot.EnvironmentNames())
+	name, _ := reader.ReadString('\n')
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "tr"
+	}
+	return bot.LookupEnvironment(name)
 }
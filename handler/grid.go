@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"dca-bot/bot"
+	"dca-bot/service"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type GridHandler struct {
+	service *service.GridService
+}
+
+func NewGridHandler() *GridHandler {
+	return &GridHandler{
+		service: service.NewGridService(),
+	}
+}
+
+func (h *GridHandler) StartCLI() error {
+	reader := stdin
+
+	fmt.Printf("Enter trading pair (e.g. btcusdt): ")
+	symbol, _ := reader.ReadString('\n')
+	symbol = strings.TrimSpace(symbol)
+
+	fmt.Printf("Enter candle interval for %s (e.g. 15m, 1h): ", symbol)
+	interval, _ := reader.ReadString('\n')
+	interval = strings.TrimSpace(interval)
+
+	fmt.Print("Enter grid capital in USDT: ")
+	usdtInput, _ := reader.ReadString('\n')
+	usdt, err := strconv.ParseFloat(strings.TrimSpace(usdtInput), 64)
+	if err != nil || usdt <= 0 {
+		return fmt.Errorf("invalid USDT amount")
+	}
+
+	fmt.Print("Exchange (binance, okx) [binance]: ")
+	exchangeName, _ := reader.ReadString('\n')
+	exchangeName = strings.TrimSpace(exchangeName)
+	if exchangeName == "" {
+		exchangeName = "binance"
+	}
+	if err := bot.CheckAPIKeys(exchangeName); err != nil {
+		return err
+	}
+
+	env, err := readEnvironment(reader)
+	if err != nil {
+		return err
+	}
+
+	feed, err := bot.NewCandleFeed(exchangeName, env)
+	if err != nil {
+		return err
+	}
+
+	return h.service.Start(feed, symbol, interval, usdt)
+}
@@ -1,11 +1,10 @@
 package handler
 
 import (
+	"dca-bot/bot"
 	"dca-bot/constant"
 	"dca-bot/service"
-	"bufio"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -21,7 +20,7 @@ func NewTradeHandler() *TradeHandler {
 }
 
 func (h *TradeHandler) StartCLI() error {
-	reader := bufio.NewReader(os.Stdin)
+	reader := stdin
 
 	fmt.Printf("Enter trading pair (e.g. btcusdt): ")
 	symbol, _ := reader.ReadString('\n')
@@ -41,9 +40,29 @@ func (h *TradeHandler) StartCLI() error {
 		stopLossPercent = 1.5
 	}
 
+	fmt.Print("Exchange (binance, okx) [binance]: ")
+	exchangeName, _ := reader.ReadString('\n')
+	exchangeName = strings.TrimSpace(exchangeName)
+	if exchangeName == "" {
+		exchangeName = "binance"
+	}
+	if err := bot.CheckAPIKeys(exchangeName); err != nil {
+		return err
+	}
+
+	env, err := readEnvironment(reader)
+	if err != nil {
+		return err
+	}
+
+	feed, err := bot.NewCandleFeed(exchangeName, env)
+	if err != nil {
+		return err
+	}
+
 	// fetch token
 	tokenMap := constant.GetTokenMap()
 	token := tokenMap[symbol].(map[string]string)[interval]
 
-	return h.service.Start(symbol, interval, token, stopLossPercent)
+	return h.service.Start(feed, symbol, interval, token, stopLossPercent)
 }
@@ -1,6 +1,15 @@
 package repository
 
-import "fmt"
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const stateDir = "data"
 
 type DCARepository struct{}
 
@@ -12,3 +21,65 @@ func (r *DCARepository) Save(symbol string, totalUsdt, dropPercent float64) {
 	fmt.Printf("[repo] DCA session saved — %s | total %.2f | drop %.2f%%\n",
 		symbol, totalUsdt, dropPercent)
 }
+
+// SaveState writes the bot state as JSON, going through a temp file so a
+// crash mid-write never leaves a truncated state behind
+func (r *DCARepository) SaveState(id string, state any) error {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := statePath(id)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadState reads a previously saved state, reporting false if there is none
+func (r *DCARepository) LoadState(id string, state any) (bool, error) {
+	data, err := os.ReadFile(statePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, state)
+}
+
+// AppendLedger adds a row to the bot's CSV trade ledger, starting a new file
+// with the header
+func (r *DCARepository) AppendLedger(id string, header, row []string) error {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(stateDir, "ledger-"+id+".csv")
+	_, err := os.Stat(path)
+	isNew := errors.Is(err, os.ErrNotExist)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if isNew {
+		w.Write(header)
+	}
+	w.Write(row)
+	w.Flush()
+	return w.Error()
+}
+
+func statePath(id string) string {
+	return filepath.Join(stateDir, "dca-"+id+".json")
+}
@@ -4,8 +4,7 @@ import (
 	"dca-bot/bot"
 	"dca-bot/repository"
 	"fmt"
-
-	bybit "github.com/bybit-exchange/bybit.go.api"
+	"time"
 )
 
 type DCAService struct {
@@ -18,20 +17,106 @@ func NewDCAService() *DCAService {
 	}
 }
 
-func (s *DCAService) Start(client *bybit.Client, symbol string, totalUSDT, dropPercent, sellPercent float64, fallbackBuyHours int) error {
-	// dcaAmount := totalUSDT * 0.01 // buy 1% per entry
+func (s *DCAService) Start(exchange bot.SpotExchange, account bot.AccountService, cfg bot.DCAConfig) error {
+	if cfg.VaultPercent < 0 || cfg.VaultPercent > 100 {
+		return fmt.Errorf("vault percent %g is not between 0 and 100", cfg.VaultPercent)
+	}
+	if cfg.Short {
+		futures, ok := exchange.(bot.FuturesExchange)
+		if !ok {
+			return fmt.Errorf("%s does not trade perpetuals", exchange.Name())
+		}
+		if err := bot.SetupFutures(futures, cfg); err != nil {
+			return err
+		}
+	}
+	plan := bot.NewDCABotFromConfig(exchange, cfg)
 
 	fmt.Println("===== DCA MODE =====")
-	fmt.Printf("Symbol: %s\n", symbol)
-	fmt.Printf("Total USDT: %.2f\n", totalUSDT)
-	fmt.Printf("Buy per entry (1%%): %.2f USDT\n", 1)
-	fmt.Printf("Drop trigger: %.2f%%\n", dropPercent)
-	fmt.Printf("Sell trigger: %.2f%%\n", sellPercent)
+	fmt.Printf("Exchange: %s\n", exchange.Name())
+	fmt.Printf("Symbol: %s\n", cfg.Symbol)
+	fmt.Printf("Total USDT: %.2f\n", cfg.TotalUSDT)
+	if cfg.Short {
+		fmt.Printf("Side: short, %gx %s margin\n", plan.Leverage, plan.MarginMode)
+		if cfg.LiqAlertPercent > 0 {
+			fmt.Printf("Liquidation alert: within %.2f%% of mark\n", cfg.LiqAlertPercent)
+		}
+	}
+	if cfg.ValueStepUSDT > 0 && cfg.Schedule != nil {
+		fmt.Printf("Value averaging: target +%.2f USDT %s\n", cfg.ValueStepUSDT, cfg.Schedule)
+		fmt.Printf("Max buy: %.2f USDT (0 = no cap)\n", cfg.MaxBuyUSDT)
+		fmt.Printf("Max sell: %.2f USDT (0 = never sell)\n", cfg.MaxSellUSDT)
+		fmt.Printf("Next slot: %s\n", cfg.Schedule.Next(time.Now()).Format("2006-01-02 15:04"))
+	} else if cfg.Schedule != nil {
+		fmt.Printf("Schedule: %.2f USDT %s, missed buys: %s\n", cfg.ScheduleUSDT, cfg.Schedule, plan.CatchUp)
+		fmt.Printf("Next buy: %s\n", cfg.Schedule.Next(time.Now()).Format("2006-01-02 15:04"))
+		if cfg.BoostPercent > 0 {
+			fmt.Printf("Boost: x%.1f when %.2f%% under the 30-day average\n", plan.BoostMultiplier, cfg.BoostPercent)
+		}
+	} else {
+		fmt.Printf("Buy per entry: %.2f USDT\n", plan.OneBuyUSDT)
+		fmt.Printf("Drop trigger: %.2f%%\n", cfg.DropPercent)
+	}
+	fmt.Printf("Sell trigger: %.2f%%\n", cfg.SellPercent)
+	if cfg.TrailingBuyRebound > 0 {
+		fmt.Printf("Trailing buy: %.2f%% rebound, max wait %d minutes (0 = no limit)\n", cfg.TrailingBuyRebound, cfg.TrailingBuyMaxWaitMinutes)
+	}
+	if plan.ATRStep.Enabled() {
+		fmt.Printf("Adaptive steps: %s, fixed %% until the first ATR\n", plan.ATRStep)
+	}
+	fmt.Printf("Take profit on exchange: %t\n", plan.NativeTakeProfit)
+	if plan.ExitMode == bot.ExitLot {
+		fmt.Println("Exits: lot by lot, every buy sells at its own target")
+	} else {
+		fmt.Printf("Cost basis: %s\n", plan.CostBasis)
+	}
+	if cfg.TrailingDeviation > 0 {
+		fmt.Printf("Trailing take profit: %.2f%% pullback\n", cfg.TrailingDeviation)
+	}
+	for i, level := range cfg.TPLadder {
+		fmt.Printf("TP level %d: %.0f%% at +%.2f%%\n", i+1, level.Share, level.Profit)
+	}
+	if cfg.StopLossPercent > 0 {
+		fmt.Printf("Stop loss: -%.2f%% from average (%s)\n", cfg.StopLossPercent, cfg.StopLossAction)
+	}
+	if cfg.MaxDrawdownUSDT > 0 {
+		fmt.Printf("Max drawdown: %.2f USDT (%s)\n", cfg.MaxDrawdownUSDT, cfg.DrawdownAction)
+	}
+	if cfg.FloorPrice > 0 {
+		fmt.Printf("Buy floor: %.4f (%s)\n", cfg.FloorPrice, cfg.FloorAction)
+	}
+	fmt.Printf("Entry filter: %s\n", cfg.EntryFilter)
+	if plan.BookGuard.Enabled() {
+		fmt.Printf("Order book guard: %s\n", plan.BookGuard)
+	}
+	if r := plan.RegimeFilter; r.Enabled {
+		fmt.Printf("Regime bull: %s\nRegime range: %s\nRegime bear: %s\n", r.Bull, r.Range, r.Bear)
+	}
+	switch plan.Reinvest {
+	case bot.ReinvestVault:
+		fmt.Printf("Reinvest: vault, %.0f%% of each profit is locked away\n", cfg.VaultPercent)
+	case bot.ReinvestCompound:
+		fmt.Println("Reinvest: compound, order sizes follow equity")
+	default:
+		fmt.Println("Reinvest: fixed order sizes")
+	}
+	switch {
+	case cfg.StopAfterDeal:
+		fmt.Println("After a deal: pause until /resume")
+	case cfg.DealCooldownMinutes > 0:
+		fmt.Printf("After a deal: %d minute cooldown\n", cfg.DealCooldownMinutes)
+	default:
+		fmt.Println("After a deal: restart right away")
+	}
+	if cfg.MaxSafetyOrders > 0 {
+		fmt.Println("===== SAFETY ORDERS =====")
+		fmt.Print(plan.LadderSummary())
+	}
 
-	s.repo.Save(symbol, totalUSDT, dropPercent) // optional persistence
+	s.repo.Save(cfg.Symbol, cfg.TotalUSDT, cfg.DropPercent) // optional persistence
 
 	// run DCA bot (websocket)
-	go bot.RunDCABot(client, symbol, totalUSDT, 1, dropPercent, sellPercent, fallbackBuyHours)
+	go bot.RunDCABot(exchange, account, s.repo, cfg)
 
 	return nil
 }
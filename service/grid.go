@@ -0,0 +1,21 @@
+package service
+
+import (
+	"dca-bot/bot"
+	"strings"
+)
+
+type GridService struct{}
+
+func NewGridService() *GridService {
+	return &GridService{}
+}
+
+func (s *GridService) Start(feed bot.CandleFeed, symbol, interval string, usdt float64) error {
+	b := bot.NewFixRangeBot(strings.ToUpper(symbol), usdt)
+
+	// runs until the process stops
+	bot.RunFixRangeBot(b, feed, interval)
+
+	return nil
+}
@@ -15,13 +15,13 @@ func NewTradeService() *TradeService {
 	}
 }
 
-func (s *TradeService) Start(symbol, interval, token string, sl float64) error {
+func (s *TradeService) Start(feed bot.CandleFeed, symbol, interval, token string, sl float64) error {
 
 	// save user session
 	s.repo.SaveSession(symbol, interval, sl)
 
 	// run your existing bot logic
-	bot.Bot(symbol, interval, token, sl)
+	bot.Bot(feed, symbol, interval, token, sl)
 
 	return nil
 }